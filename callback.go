@@ -16,51 +16,41 @@ import (
 	"github.com/sulrex/gopay/util"
 )
 
-// AliWebCallback ..
+// AliWebCallback 支付宝手机网站支付回调
 func AliWebCallback(w http.ResponseWriter, r *http.Request) (*common.AliWebPayResult, error) {
-	var m = make(map[string]string)
-	var signSlice []string
-	r.ParseForm()
-	for k, v := range r.Form {
-		// k不会有多个值的情况
-		m[k] = v[0]
-		if k == "sign" || k == "sign_type" {
-			continue
-		}
-		signSlice = append(signSlice, fmt.Sprintf("%s=%s", k, v[0]))
-	}
-
-	sort.Strings(signSlice)
-	signData := strings.Join(signSlice, "&")
-	if m["sign_type"] != "RSA" {
-		//错误日志
-		panic("签名类型未知")
-	}
-
-	client.DefaultAliWebClient().CheckSign(signData, m["sign"])
-
-	var aliPay common.AliWebPayResult
-	err := util.MapStringToStruct(m, &aliPay)
-	if err != nil {
+	c := client.DefaultAliWebClient()
+	if c == nil {
 		w.Write([]byte("error"))
-		panic(err)
+		return nil, ErrClientNotConfigured
 	}
-
-	w.Write([]byte("success"))
-	return &aliPay, nil
+	return aliCallback(w, r, c.VerifySign)
 }
 
 // AliAppCallback 支付宝app支付回调
 func AliAppCallback(w http.ResponseWriter, r *http.Request) (*common.AliWebPayResult, error) {
-	var result string
+	c := client.DefaultAliAppClient()
+	if c == nil {
+		w.Write([]byte("error"))
+		return nil, ErrClientNotConfigured
+	}
+	return aliCallback(w, r, c.VerifySign)
+}
+
+// aliCallback 按通知声明的sign_type验签并解析支付宝异步通知
+func aliCallback(w http.ResponseWriter, r *http.Request, verify func(signType, signData, sign string) error) (*common.AliWebPayResult, error) {
+	var result = "error"
 	defer func() {
 		w.Write([]byte(result))
 	}()
 
 	var m = make(map[string]string)
 	var signSlice []string
-	r.ParseForm()
+	err := r.ParseForm()
+	if err != nil {
+		return nil, errors.New("r.ParseForm: " + err.Error())
+	}
 	for k, v := range r.Form {
+		// k不会有多个值的情况
 		m[k] = v[0]
 		if k == "sign" || k == "sign_type" {
 			continue
@@ -69,24 +59,16 @@ func AliAppCallback(w http.ResponseWriter, r *http.Request) (*common.AliWebPayRe
 	}
 	sort.Strings(signSlice)
 	signData := strings.Join(signSlice, "&")
-	if m["sign_type"] != "RSA" {
-		result = "error"
-		panic("签名类型未知")
-	}
 
-	client.DefaultAliAppClient().CheckSign(signData, m["sign"])
-
-	mByte, err := json.Marshal(m)
+	err = verify(m["sign_type"], signData, m["sign"])
 	if err != nil {
-		result = "error"
-		panic(err)
+		return nil, errors.New("alipay notify check sign: " + err.Error())
 	}
 
 	var aliPay common.AliWebPayResult
-	err = json.Unmarshal(mByte, &aliPay)
+	err = util.MapStringToStruct(m, &aliPay)
 	if err != nil {
-		result = "error"
-		panic(fmt.Sprintf("m is %v, err is %v", m, err))
+		return nil, errors.New("util.MapStringToStruct: " + err.Error())
 	}
 	result = "success"
 	return &aliPay, nil
@@ -134,7 +116,10 @@ func WeChatWebCallback(w http.ResponseWriter, r *http.Request) (*common.WeChatPa
 		signData = append(signData, fmt.Sprintf("%v=%v", k, v))
 	}
 
-	key := client.DefaultWechatAppClient().Key
+	key, err := client.DefaultWechatAppClient().SignKey()
+	if err != nil {
+		return &reXML, err
+	}
 
 	mySign, err := client.WechatGenSign(key, m)
 	if err != nil {
@@ -191,7 +176,10 @@ func WeChatAppCallback(w http.ResponseWriter, r *http.Request) (*common.WeChatPa
 		signData = append(signData, fmt.Sprintf("%v=%v", k, v))
 	}
 
-	key := client.DefaultWechatAppClient().Key
+	key, err := client.DefaultWechatAppClient().SignKey()
+	if err != nil {
+		return &reXML, err
+	}
 
 	mySign, err := client.WechatGenSign(key, m)
 	if err != nil {
@@ -205,3 +193,78 @@ func WeChatAppCallback(w http.ResponseWriter, r *http.Request) (*common.WeChatPa
 	returnCode = "SUCCESS"
 	return &reXML, nil
 }
+
+// WeChatV3Callback 微信支付v3回调, 验签并解密支付通知(多商户时传入对应客户端)
+func WeChatV3Callback(w http.ResponseWriter, r *http.Request, c *client.WechatV3Client) (*common.WeChatV3Transaction, error) {
+	var returnCode = "FAIL"
+	var returnMsg = ""
+	defer func() {
+		if returnCode == "SUCCESS" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		returnBody, _ := json.Marshal(map[string]string{"code": returnCode, "message": returnMsg})
+		w.Write(returnBody)
+	}()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		returnMsg = "Bodyerror"
+		return nil, err
+	}
+
+	var transaction common.WeChatV3Transaction
+	_, err = c.ParseNotify(r.Header, body, &transaction)
+	if err != nil {
+		returnMsg = "签名或解密错误"
+		return nil, err
+	}
+
+	returnCode = "SUCCESS"
+	return &transaction, nil
+}
+
+// WechatSignKeyer 可取得签名密钥的微信客户端, 如WechatAppClient、WechatWebClient、WechatMiniProgramClient
+type WechatSignKeyer interface {
+	SignKey() (string, error)
+}
+
+// WeChatRefundCallback 微信退款结果通知, 用发起退款的客户端密钥解密req_info(多商户时传入对应客户端)
+func WeChatRefundCallback(w http.ResponseWriter, r *http.Request, c WechatSignKeyer) (*common.WeChatRefundResult, error) {
+	var returnCode = "FAIL"
+	var returnMsg = ""
+	defer func() {
+		formatStr := `<xml><return_code><![CDATA[%s]]></return_code>
+                  <return_msg><![CDATA[%s]]></return_msg></xml>`
+		returnBody := fmt.Sprintf(formatStr, returnCode, returnMsg)
+		w.Write([]byte(returnBody))
+	}()
+
+	if c == nil {
+		returnMsg = "客户端未配置"
+		return nil, ErrClientNotConfigured
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		returnMsg = "Bodyerror"
+		return nil, err
+	}
+
+	key, err := c.SignKey()
+	if err != nil {
+		returnMsg = "获取签名密钥失败"
+		return nil, err
+	}
+
+	re, err := client.WechatParseRefundNotify(key, body)
+	if err != nil {
+		returnMsg = "参数错误"
+		return re, err
+	}
+
+	returnCode = "SUCCESS"
+	return re, nil
+}
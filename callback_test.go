@@ -0,0 +1,74 @@
+package gopay
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sulrex/gopay/client"
+)
+
+func TestAliCallbackSignType(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 客户端按RSA2配置, 通知按RSA签名
+	c := &client.AliAppClient{PrivateKey: key, PublicKey: &key.PublicKey, SignType: client.AliSignTypeRSA2}
+	notifier := &client.AliAppClient{PrivateKey: key, SignType: client.AliSignTypeRSA}
+	params := map[string]string{
+		"out_trade_no": "T1",
+		"trade_no":     "2016000000000001",
+		"trade_status": "TRADE_SUCCESS",
+		"total_amount": "0.01",
+	}
+	sign := notifier.GenSign(params)
+
+	notify := func(signType string) (*httptest.ResponseRecorder, error) {
+		form := url.Values{}
+		for k, v := range params {
+			form.Set(k, v)
+		}
+		form.Set("sign", sign)
+		form.Set("sign_type", signType)
+		r := httptest.NewRequest("POST", "/notify", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		_, err := aliCallback(w, r, c.VerifySign)
+		return w, err
+	}
+
+	w, err := notify(client.AliSignTypeRSA)
+	if err != nil || w.Body.String() != "success" {
+		t.Fatalf("RSA notify: %v, response %q", err, w.Body.String())
+	}
+	w, err = notify(client.AliSignTypeRSA2)
+	if err == nil || w.Body.String() != "error" {
+		t.Fatalf("RSA2 notify with RSA sign accepted, response %q", w.Body.String())
+	}
+}
+
+func TestWeChatRefundCallback(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/refund", strings.NewReader("<xml></xml>"))
+	if _, err := WeChatRefundCallback(w, r, nil); !errors.Is(err, ErrClientNotConfigured) {
+		t.Fatalf("WeChatRefundCallback returned %v, want ErrClientNotConfigured", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<return_code><![CDATA[FAIL]]></return_code>") || !strings.Contains(body, "<return_msg><![CDATA[") {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/refund", strings.NewReader("<xml><return_code>SUCCESS</return_code><req_info><![CDATA[AAAA]]></req_info></xml>"))
+	c := &client.WechatAppClient{MchID: "10000100", Key: "192006250b4c09247ec02edce69f6a2d"}
+	if _, err := WeChatRefundCallback(w, r, c); err == nil {
+		t.Fatal("WeChatRefundCallback accepted bad req_info")
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<return_code><![CDATA[FAIL]]></return_code>") {
+		t.Fatalf("unexpected response %s", body)
+	}
+}
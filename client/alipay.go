@@ -0,0 +1,305 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+// AliError 支付宝开放平台业务错误
+type AliError struct {
+	Code    string `json:"code"`
+	Msg     string `json:"msg"`
+	SubCode string `json:"sub_code"`
+	SubMsg  string `json:"sub_msg"`
+}
+
+func (e *AliError) Error() string {
+	return fmt.Sprintf("alipay: code=%s, msg=%s, sub_code=%s, sub_msg=%s", e.Code, e.Msg, e.SubCode, e.SubMsg)
+}
+
+// aliOpenAPI 支付宝开放平台请求, 由各客户端提供配置
+type aliOpenAPI struct {
+	appID     string
+	gateway   string
+	signType  string
+	aesKey    string
+	genSign   func(m map[string]string) string
+	checkSign func(signData, sign string) error
+	hc        *HTTPSClient // 为空时使用HTTPSC
+
+	// 公钥证书模式, appCertSN为空时使用普通公钥模式
+	appCertSN    string
+	rootCertSN   string
+	alipayCertSN string
+}
+
+// params 生成公共请求参数(不含sign), 配置了AES密钥时加密biz_content
+func (a aliOpenAPI) params(method string, bizContent interface{}) (map[string]string, error) {
+	var m = make(map[string]string)
+	m["app_id"] = a.appID
+	m["method"] = method
+	m["format"] = "JSON"
+	m["charset"] = "utf-8"
+	m["sign_type"] = a.signType
+	m["timestamp"] = util.Now().Format("2006-01-02 15:04:05")
+	m["version"] = "1.0"
+	if a.appCertSN != "" {
+		m["app_cert_sn"] = a.appCertSN
+		m["alipay_root_cert_sn"] = a.rootCertSN
+	}
+
+	bizContentJSON, err := json.Marshal(bizContent)
+	if err != nil {
+		return m, errors.New("json.Marshal: " + err.Error())
+	}
+	m["biz_content"] = string(bizContentJSON)
+	if a.aesKey != "" {
+		m["biz_content"], err = AliEncrypt(a.aesKey, m["biz_content"])
+		if err != nil {
+			return m, err
+		}
+		m["encrypt_type"] = "AES"
+	}
+	return m, nil
+}
+
+// do 调用开放平台接口, 验证应答签名, 解密后解析到out
+func (a aliOpenAPI) do(ctx context.Context, method string, bizContent interface{}, out interface{}) error {
+	m, err := a.params(method, bizContent)
+	if err != nil {
+		return err
+	}
+	m["sign"] = a.genSign(m)
+
+	var values = url.Values{}
+	for k, v := range m {
+		values.Set(k, v)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.gateway, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+	hc := a.hc
+	if hc == nil {
+		hc = HTTPSC
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return errors.New("HTTPSC.Do: " + err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("alipay: http status %d", resp.StatusCode)
+	}
+	return a.parseResponse(method, body, out)
+}
+
+// queryTrade 订单查询(alipay.trade.query)
+func (a aliOpenAPI) queryTrade(ctx context.Context, q common.AliTradeQuery) (common.AliWebAppQueryResult, error) {
+	var re common.AliWebAppQueryResult
+	if q.TradeNo == "" && q.OutTradeNo == "" {
+		return re, errors.New("alipay: trade_no or out_trade_no is required")
+	}
+	err := a.do(ctx, "alipay.trade.query", q, &re.AlipayTradeQueryResponse)
+	return re, err
+}
+
+// query 按商户订单号查询并转为统一结果. 用户未打开收银台时支付宝尚未创建交易,
+// 返回ACQ.TRADE_NOT_EXIST, 按未支付处理
+func (a aliOpenAPI) query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	re, err := a.queryTrade(ctx, common.AliTradeQuery{OutTradeNo: tradeNum})
+	var aliErr *AliError
+	if errors.As(err, &aliErr) && aliErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+		return &common.QueryResult{
+			TradeNum:      tradeNum,
+			TradeState:    common.TradeStateNotPay,
+			ProviderState: aliErr.SubCode,
+			Metadata:      map[string]string{},
+			Raw:           re,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return aliQueryResult(re)
+}
+
+// parseResponse 取出应答内容验签(加密应答对密文验签), 解密后解析到out.
+// 业务结果不为10000时out仍会被填充, 同时返回*AliError.
+// 公钥证书模式下错误应答也必须带签名, 否则不返回*AliError
+func (a aliOpenAPI) parseResponse(method string, body []byte, out interface{}) error {
+	var re map[string]json.RawMessage
+	err := json.Unmarshal(body, &re)
+	if err != nil {
+		return errors.New("json.Unmarshal: " + err.Error())
+	}
+	content, ok := re[strings.Replace(method, ".", "_", -1)+"_response"]
+	if !ok {
+		content, ok = re["error_response"]
+	}
+	if !ok {
+		return errors.New("alipay: response not found in " + string(body))
+	}
+
+	var sign, certSN string
+	json.Unmarshal(re["sign"], &sign)
+	json.Unmarshal(re["alipay_cert_sn"], &certSN)
+	if certSN != "" && a.alipayCertSN != "" && certSN != a.alipayCertSN {
+		// 支付宝公钥证书已更换, 需下载新证书
+		return errors.New("alipay: response signed by cert " + certSN + ", expected " + a.alipayCertSN)
+	}
+	if sign == "" && a.appCertSN != "" {
+		return errors.New("alipay: response without sign")
+	}
+	if sign != "" {
+		err = a.checkSign(string(content), sign)
+		if err != nil {
+			return errors.New("alipay: check sign: " + err.Error())
+		}
+	}
+
+	if len(content) > 0 && content[0] == '"' {
+		var encrypted string
+		err = json.Unmarshal(content, &encrypted)
+		if err != nil {
+			return errors.New("json.Unmarshal: " + err.Error())
+		}
+		if a.aesKey == "" {
+			return errors.New("alipay: encrypted response without AES key")
+		}
+		plain, err := AliDecrypt(a.aesKey, encrypted)
+		if err != nil {
+			return err
+		}
+		content = []byte(plain)
+	}
+
+	var aliErr AliError
+	err = json.Unmarshal(content, &aliErr)
+	if err != nil {
+		return errors.New("json.Unmarshal: " + err.Error())
+	}
+	if out != nil {
+		err = json.Unmarshal(content, out)
+		if err != nil {
+			return errors.New("json.Unmarshal: " + err.Error())
+		}
+	}
+	if aliErr.Code != "10000" {
+		return &aliErr
+	}
+	if sign == "" {
+		return errors.New("alipay: response without sign")
+	}
+	return nil
+}
+
+// 支付宝签名类型
+const (
+	AliSignTypeRSA  = "RSA"  // SHA1WithRSA, 支付宝已不建议新应用使用
+	AliSignTypeRSA2 = "RSA2" // SHA256WithRSA
+)
+
+// aliHash 签名类型对应的摘要算法
+func aliHash(signType string) (crypto.Hash, error) {
+	switch signType {
+	case AliSignTypeRSA:
+		return crypto.SHA1, nil
+	case AliSignTypeRSA2:
+		return crypto.SHA256, nil
+	}
+	return 0, errors.New("alipay: unknown sign_type " + signType)
+}
+
+// aliSign 按签名类型签名
+func aliSign(privateKey *rsa.PrivateKey, signType, signData string) (string, error) {
+	hash, err := aliHash(signType)
+	if err != nil {
+		return "", err
+	}
+	h := hash.New()
+	h.Write([]byte(signData))
+	signByte, err := rsa.SignPKCS1v15(rand.Reader, privateKey, hash, h.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signByte), nil
+}
+
+// aliVerify 按签名类型验签
+func aliVerify(publicKey *rsa.PublicKey, signType, signData, sign string) error {
+	hash, err := aliHash(signType)
+	if err != nil {
+		return err
+	}
+	signByte, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write([]byte(signData))
+	return rsa.VerifyPKCS1v15(publicKey, hash, h.Sum(nil), signByte)
+}
+
+// AliEncrypt AES加密(CBC, 全零IV, PKCS5填充), aesKey为base64编码密钥
+func AliEncrypt(aesKey, content string) (string, error) {
+	block, err := aliAESBlock(aesKey)
+	if err != nil {
+		return "", err
+	}
+	data := pkcs7Pad([]byte(content), block.BlockSize())
+	cipher.NewCBCEncrypter(block, make([]byte, block.BlockSize())).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// AliDecrypt AES解密, 与AliEncrypt对应
+func AliDecrypt(aesKey, content string) (string, error) {
+	block, err := aliAESBlock(aesKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return "", errors.New("AliDecrypt base64: " + err.Error())
+	}
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return "", errors.New("AliDecrypt: invalid ciphertext length")
+	}
+	cipher.NewCBCDecrypter(block, make([]byte, block.BlockSize())).CryptBlocks(data, data)
+	data, err = pkcs7Unpad(data, block.BlockSize())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
+
+func aliAESBlock(aesKey string) (cipher.Block, error) {
+	key, err := base64.StdEncoding.DecodeString(aesKey)
+	if err != nil {
+		return nil, errors.New("alipay aes key base64: " + err.Error())
+	}
+	return aes.NewCipher(key)
+}
@@ -1,19 +1,12 @@
 package client
 
 import (
-	"crypto"
-	"crypto/rand"
+	"context"
 	"crypto/rsa"
-	"crypto/sha1"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"net/url"
 	"sort"
 	"strings"
-	"time"
-
-	"errors"
 
 	"github.com/sulrex/gopay/common"
 )
@@ -26,6 +19,17 @@ type AliAppClient struct {
 	AppID      string // 应用ID
 	PrivateKey *rsa.PrivateKey
 	PublicKey  *rsa.PublicKey
+	AESKey     string // AES密钥(base64), 设置后biz_content加密传输
+	SignType   string // 签名类型RSA或RSA2, 默认RSA2
+
+	SettleCurrency string // 结算币种, 跨境商户使用
+
+	// 公钥证书模式, 资金类接口(转账)必须使用, 可用LoadCerts设置
+	AppCertSN        string // 应用公钥证书序列号
+	AlipayRootCertSN string // 支付宝根证书序列号
+	AlipayCertSN     string // 支付宝公钥证书序列号, 与应答中的alipay_cert_sn比对
+
+	FundClient *HTTPSClient // 资金类接口使用的客户端, 须校验服务端证书, 为空时使用VerifiedHTTPSC
 }
 
 // InitAliAppClient ..
@@ -40,27 +44,34 @@ func DefaultAliAppClient() *AliAppClient {
 
 // Pay ..
 func (ac *AliAppClient) Pay(charge *common.Charge) (map[string]string, error) {
-	var m = make(map[string]string)
-	var bizContent = make(map[string]string)
-	m["app_id"] = ac.AppID
-	m["method"] = "alipay.trade.app.pay"
-	m["format"] = "JSON"
-	m["charset"] = "utf-8"
-	m["timestamp"] = time.Now().Format("2006-01-02 15:04:05")
-	m["version"] = "1.0"
-	m["notify_url"] = charge.CallbackURL
-	m["sign_type"] = "RSA"
+	var bizContent = make(map[string]interface{})
 	bizContent["subject"] = TruncatedText(charge.Describe, 32)
 	bizContent["out_trade_no"] = charge.TradeNum
 	bizContent["product_code"] = "QUICK_MSECURITY_PAY"
-	bizContent["total_amount"] = AliyunMoneyFeeToString(charge.MoneyFee)
-
-	bizContentJSON, err := json.Marshal(bizContent)
+	err := aliAmountParams(charge, ac.SettleCurrency, bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	err = aliExpireParams(charge, bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	if len(charge.Metadata) > 0 {
+		bizContent["passback_params"], err = aliPassbackParams(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	err = aliGoodsParams(charge, bizContent)
 	if err != nil {
-		return map[string]string{}, errors.New("json.Marshal: " + err.Error())
+		return map[string]string{}, err
 	}
-	m["biz_content"] = string(bizContentJSON)
 
+	m, err := ac.openAPI().params("alipay.trade.app.pay", bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	m["notify_url"] = charge.CallbackURL
 	m["sign"] = ac.GenSign(m)
 
 	return map[string]string{"orderString": ac.ToURL(m)}, nil
@@ -68,26 +79,47 @@ func (ac *AliAppClient) Pay(charge *common.Charge) (map[string]string, error) {
 
 // QueryOrder 订单查询
 func (ac *AliAppClient) QueryOrder(outTradeNo string) (common.AliWebAppQueryResult, error) {
-	var m = make(map[string]string)
-	m["method"] = "alipay.trade.query"
-	m["app_id"] = ac.AppID
-	m["format"] = "JSON"
-	m["charset"] = "utf-8"
-	m["timestamp"] = time.Now().Format("2006-01-02 15:04:05")
-	m["version"] = "1.0"
-	m["sign_type"] = "RSA"
-	bizContent := map[string]string{"out_trade_no": outTradeNo}
-	bizContentJSON, err := json.Marshal(bizContent)
-	if err != nil {
-		return common.AliWebAppQueryResult{}, errors.New("json.Marshal: " + err.Error())
-	}
-	m["biz_content"] = string(bizContentJSON)
-	sign := ac.GenSign(m)
-	m["sign"] = sign
+	return ac.queryOrder(context.Background(), outTradeNo)
+}
 
-	url := fmt.Sprintf("%s?%s", "https://openapi.alipay.com/gateway.do", ac.ToURL(m))
+// Query 查询订单, 返回统一的查询结果
+func (ac *AliAppClient) Query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	return ac.openAPI().query(ctx, tradeNum)
+}
 
-	return GetAlipayApp(url)
+// QueryTrade 按支付宝交易号或商户订单号查询订单
+func (ac *AliAppClient) QueryTrade(ctx context.Context, q common.AliTradeQuery) (common.AliWebAppQueryResult, error) {
+	return ac.openAPI().queryTrade(ctx, q)
+}
+
+func (ac *AliAppClient) queryOrder(ctx context.Context, outTradeNo string) (common.AliWebAppQueryResult, error) {
+	return ac.QueryTrade(ctx, common.AliTradeQuery{OutTradeNo: outTradeNo})
+}
+
+// openAPI 开放平台请求配置
+func (ac *AliAppClient) openAPI() aliOpenAPI {
+	return aliOpenAPI{
+		appID:     ac.AppID,
+		gateway:   aliGateWay,
+		signType:  ac.signType(),
+		aesKey:    ac.AESKey,
+		genSign:   ac.GenSign,
+		checkSign: ac.checkSign,
+
+		appCertSN:    ac.AppCertSN,
+		rootCertSN:   ac.AlipayRootCertSN,
+		alipayCertSN: ac.AlipayCertSN,
+	}
+}
+
+// fundAPI 资金类接口, 使用校验服务端证书的客户端
+func (ac *AliAppClient) fundAPI() aliOpenAPI {
+	a := ac.openAPI()
+	a.hc = ac.FundClient
+	if a.hc == nil {
+		a.hc = VerifiedHTTPSC
+	}
+	return a
 }
 
 // GenSign 产生签名
@@ -102,36 +134,36 @@ func (ac *AliAppClient) GenSign(m map[string]string) string {
 	sort.Strings(data)
 	signData := strings.Join(data, "&")
 
-	s := sha1.New()
-	_, err := s.Write([]byte(signData))
+	sign, err := aliSign(ac.PrivateKey, ac.signType(), signData)
 	if err != nil {
 		panic(err)
 	}
-	hashByte := s.Sum(nil)
-	signByte, err := ac.PrivateKey.Sign(rand.Reader, hashByte, crypto.SHA1)
-	if err != nil {
-		panic(err)
-	}
-
-	return base64.StdEncoding.EncodeToString(signByte)
+	return sign
 }
 
-// CheckSign 检测签名
+// CheckSign 按配置的签名类型检测签名, 验签失败时panic, 需要返回错误时使用VerifySign
 func (ac *AliAppClient) CheckSign(signData, sign string) {
-	signByte, err := base64.StdEncoding.DecodeString(sign)
-	if err != nil {
+	if err := ac.checkSign(signData, sign); err != nil {
 		panic(err)
 	}
-	s := sha1.New()
-	_, err = s.Write([]byte(signData))
-	if err != nil {
-		panic(err)
-	}
-	hash := s.Sum(nil)
-	err = rsa.VerifyPKCS1v15(ac.PublicKey, crypto.SHA1, hash, signByte)
-	if err != nil {
-		panic(err)
+}
+
+// checkSign 按配置的签名类型检测签名
+func (ac *AliAppClient) checkSign(signData, sign string) error {
+	return ac.VerifySign(ac.signType(), signData, sign)
+}
+
+// VerifySign 按指定的签名类型(通知中的sign_type)检测签名
+func (ac *AliAppClient) VerifySign(signType, signData, sign string) error {
+	return aliVerify(ac.PublicKey, signType, signData, sign)
+}
+
+// signType 签名类型, 默认RSA2
+func (ac *AliAppClient) signType() string {
+	if ac.SignType == "" {
+		return AliSignTypeRSA2
 	}
+	return ac.SignType
 }
 
 // ToURL ..
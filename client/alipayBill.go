@@ -0,0 +1,286 @@
+package client
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+)
+
+// DownloadBill 下载业务账单(商户收单交易明细), date取其年月日, 读取完需Close
+func (ac *AliAppClient) DownloadBill(ctx context.Context, date time.Time) (*BillIterator, error) {
+	return ac.openAPI().downloadBill(ctx, date)
+}
+
+// DownloadBill 下载业务账单(商户收单交易明细), date取其年月日, 读取完需Close
+func (ac *AliWebClient) DownloadBill(ctx context.Context, date time.Time) (*BillIterator, error) {
+	return ac.openAPI().downloadBill(ctx, date)
+}
+
+// downloadBill 查询账单下载地址(alipay.data.dataservice.bill.downloadurl.query),
+// 下载zip到临时文件后解析, Close时删除临时文件
+func (a aliOpenAPI) downloadBill(ctx context.Context, date time.Time) (*BillIterator, error) {
+	biz := map[string]string{"bill_type": "trade", "bill_date": date.Format("2006-01-02")}
+	var re struct {
+		BillDownloadURL string `json:"bill_download_url"`
+	}
+	err := a.do(ctx, "alipay.data.dataservice.bill.downloadurl.query", biz, &re)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", re.BillDownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPSC.Do(req)
+	if err != nil {
+		return nil, errors.New("HTTPSC.Do: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alipay bill: http status %d", resp.StatusCode)
+	}
+
+	f, err := ioutil.TempFile("", "alipay-bill-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	tmp := tempFile{f}
+	size, err := io.Copy(f, resp.Body)
+	if err != nil {
+		tmp.Close()
+		return nil, errors.New("alipay bill download: " + err.Error())
+	}
+	it, err := ParseAliBill(f, size)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	it.closers = append(it.closers, tmp)
+	return it, nil
+}
+
+// ParseAliBill 解析支付宝业务账单zip(GBK编码的明细和汇总csv), 可用于解析已下载的账单文件.
+// 汇总在返回时即可用, 明细逐条读取
+func ParseAliBill(r io.ReaderAt, size int64) (*BillIterator, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("alipay bill zip.NewReader: " + err.Error())
+	}
+	var detail *zip.File
+	p := &aliBillParser{}
+	for _, f := range zr.File {
+		name := f.Name
+		if !utf8.ValidString(name) {
+			name, _ = simplifiedchinese.GBK.NewDecoder().String(name)
+		}
+		switch {
+		case strings.HasSuffix(name, "/"):
+		case strings.Contains(name, "汇总"):
+			p.sum, err = parseAliBillSummary(f)
+			if err != nil {
+				return nil, err
+			}
+		case strings.Contains(name, "明细"):
+			detail = f
+		}
+	}
+	if detail == nil {
+		return nil, errors.New("alipay bill: detail file not found")
+	}
+	rc, err := detail.Open()
+	if err != nil {
+		return nil, err
+	}
+	p.r = newAliBillReader(rc)
+	return &BillIterator{p: p, closers: closers{rc}}, nil
+}
+
+// aliBillParser 支付宝业务明细: #开头的说明行, 表头和数据行
+type aliBillParser struct {
+	r      *csv.Reader
+	header []string
+	sum    *common.BillSummary
+	row    int
+}
+
+func (p *aliBillParser) next() (*common.BillRecord, error) {
+	for {
+		row, err := p.r.Read()
+		if err == io.EOF {
+			if p.header == nil {
+				return nil, errors.New("alipay bill: empty bill")
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, errors.New("alipay bill: " + err.Error())
+		}
+		p.row++
+		if p.header == nil {
+			p.header = aliBillHeader(row)
+			continue
+		}
+		f, err := aliBillFields(p.header, row)
+		if err != nil {
+			return nil, errors.New("alipay bill row " + strconv.Itoa(p.row) + ": " + err.Error())
+		}
+		r, err := aliBillRecord(f)
+		if err != nil {
+			return nil, errors.New("alipay bill row " + strconv.Itoa(p.row) + ": " + err.Error())
+		}
+		return r, nil
+	}
+}
+
+func (p *aliBillParser) summary() *common.BillSummary {
+	return p.sum
+}
+
+// aliBillRecord 转为与微信账单一致的记录: 交易为SUCCESS, 退款为REFUND且退款金额为正;
+// 支付宝服务费从商户扣除时为负数, 取反后与微信手续费同号
+func aliBillRecord(f billFields) (*common.BillRecord, error) {
+	d := currencyDecimals(constant.CNY)
+	amount := f.amount(d, "订单金额")
+	r := &common.BillRecord{
+		TradeTime:     f.time("完成时间"),
+		DeviceInfo:    f.str("终端号"),
+		TransactionID: f.str("支付宝交易号"),
+		TradeNum:      f.str("商户订单号"),
+		Payer:         f.str("对方账户"),
+		TradeType:     f.str("业务类型"),
+		Currency:      constant.CNY,
+		CouponFee:     f.amount(d, "商家优惠"),
+		Subject:       f.str("商品名称"),
+		ServiceFee:    -f.amount(d, "服务费"),
+	}
+	if r.TradeTime.IsZero() {
+		r.TradeTime = f.time("创建时间")
+	}
+	switch r.TradeType {
+	case "退款":
+		r.TradeState = "REFUND"
+		r.OutRefundNo = f.str("退款批次号/请求号")
+		r.RefundFee = -amount
+		r.ApplyRefundFee = -amount
+		r.RefundSuccessTime = r.TradeTime
+		r.RefundStatus = "SUCCESS"
+	default:
+		r.TradeState = "SUCCESS"
+		r.TotalFee = amount
+		r.SettlementFee = f.amount(d, "商家实收")
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return r, nil
+}
+
+// parseAliBillSummary 解析业务汇总, 有合计行时取合计行, 否则累加各门店
+func parseAliBillSummary(zf *zip.File) (*common.BillSummary, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	r := newAliBillReader(rc)
+	var header []string
+	var total *common.BillSummary
+	sum := &common.BillSummary{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("alipay bill summary: " + err.Error())
+		}
+		if header == nil {
+			header = aliBillHeader(row)
+			continue
+		}
+		f, err := aliBillFields(header, row)
+		if err != nil {
+			return nil, errors.New("alipay bill summary: " + err.Error())
+		}
+		d := currencyDecimals(constant.CNY)
+		s := &common.BillSummary{
+			TradeCount:    f.count("交易订单总笔数") + f.count("退款订单总笔数"),
+			SettlementFee: f.amount(d, "商家实收"),
+			ServiceFee:    -f.amount(d, "服务费"),
+			TotalFee:      f.amount(d, "订单金额"),
+		}
+		if f.err != nil {
+			return nil, errors.New("alipay bill summary: " + f.err.Error())
+		}
+		if f.str("门店编号") == "合计" {
+			total = s
+			continue
+		}
+		sum.TradeCount += s.TradeCount
+		sum.SettlementFee += s.SettlementFee
+		sum.ServiceFee += s.ServiceFee
+		sum.TotalFee += s.TotalFee
+	}
+	if total != nil {
+		return total, nil
+	}
+	return sum, nil
+}
+
+// newAliBillReader 支付宝账单为GBK编码, #开头的行为说明
+func newAliBillReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(simplifiedchinese.GBK.NewDecoder().Reader(r))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return cr
+}
+
+// aliBillHeader 表头去掉金额单位, 如"订单金额（元）"为"订单金额"
+func aliBillHeader(row []string) []string {
+	header := make([]string, len(row))
+	for i, h := range row {
+		h = strings.TrimSpace(h)
+		h = strings.TrimSuffix(h, "（元）")
+		header[i] = strings.TrimSuffix(h, "(元)")
+	}
+	return header
+}
+
+func aliBillFields(header, row []string) (billFields, error) {
+	if len(row) != len(header) {
+		return billFields{}, errors.New("expected " + strconv.Itoa(len(header)) + " fields, got " + strconv.Itoa(len(row)))
+	}
+	m := make(map[string]string, len(header))
+	for i, h := range header {
+		m[h] = strings.TrimSpace(row[i])
+	}
+	return billFields{m: m}, nil
+}
+
+// tempFile 关闭时删除的临时文件
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
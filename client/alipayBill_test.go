@@ -0,0 +1,64 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestParseAliBill(t *testing.T) {
+	detail := "#支付宝业务明细查询\r\n#账号：[20886xxxxxx0156]\r\n" +
+		"支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户,订单金额（元）,商家实收（元）,支付宝红包（元）,集分宝（元）,支付宝优惠（元）,商家优惠（元）,券核销金额（元）,券名称,商家红包消费金额（元）,卡消费金额（元）,退款批次号/请求号,服务费（元）,分润（元）,备注\r\n" +
+		"2016081721001004050200116681\t,20160817001\t,交易\t,测试商品\t,2016-08-17 10:00:00\t,2016-08-17 10:00:05\t,\t,\t,\t,\t,abc@163.com\t,12.30\t,12.30\t,0.00\t,0.00\t,0.00\t,1.00\t,0.00\t,\t,0.00\t,0.00\t,\t,-0.07\t,0.00\t,\r\n" +
+		"2016081721001004050200116681\t,20160817001\t,退款\t,测试商品\t,2016-08-17 11:00:00\t,2016-08-17 11:00:01\t,\t,\t,\t,\t,abc@163.com\t,-2.30\t,-2.30\t,0.00\t,0.00\t,0.00\t,0.00\t,0.00\t,\t,0.00\t,0.00\t,20160817001-1\t,0.01\t,0.00\t,\r\n" +
+		"#-----------------------------------------业务明细列表结束------------------------------------\r\n"
+	summary := "#支付宝业务汇总查询\r\n" +
+		"门店编号,门店名称,交易订单总笔数,退款订单总笔数,订单金额（元）,商家实收（元）,支付宝优惠（元）,商家优惠（元）,卡消费金额（元）,服务费（元）,分润（元）,实收净额（元）\r\n" +
+		"S001,一号店,1,1,10.00,10.00,0.00,1.00,0.00,-0.06,0.00,9.94\r\n"
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{"20886_20160817_业务明细.csv": detail, "20886_20160817_业务明细(汇总).csv": summary} {
+		gbkName, _ := simplifiedchinese.GBK.NewEncoder().String(name)
+		gbkBody, _ := simplifiedchinese.GBK.NewEncoder().String(body)
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: gbkName, NonUTF8: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(gbkBody))
+	}
+	zw.Close()
+
+	it, err := ParseAliBill(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	if s := it.Summary(); s == nil || s.TradeCount != 2 || s.TotalFee != 1000 || s.ServiceFee != 6 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	r := it.Record()
+	if r.TradeState != "SUCCESS" || r.TotalFee != 1230 || r.CouponFee != 100 || r.ServiceFee != 7 || r.Subject != "测试商品" || r.TradeTime.Unix() != 1471399205 {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	r = it.Record()
+	if r.TradeState != "REFUND" || r.RefundFee != 230 || r.TotalFee != 0 || r.ServiceFee != -1 || r.OutRefundNo != "20160817001-1" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if it.Next() || it.Err() != nil {
+		t.Fatalf("unexpected trailing record %+v, %v", it.Record(), it.Err())
+	}
+
+	if _, err := ParseAliBill(strings.NewReader("not a zip"), 9); err == nil {
+		t.Fatal("parsed an invalid zip")
+	}
+}
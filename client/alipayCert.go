@@ -0,0 +1,75 @@
+package client
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+// LoadCerts 使用公钥证书模式: 解析应用公钥证书、支付宝公钥证书和支付宝根证书(PEM),
+// 设置三个证书序列号, 并以支付宝公钥证书中的公钥验签
+func (ac *AliAppClient) LoadCerts(appCert, alipayCert, rootCert []byte) error {
+	app, err := parseCertPEM(appCert)
+	if err != nil {
+		return errors.New("app cert: " + err.Error())
+	}
+	alipay, err := parseCertPEM(alipayCert)
+	if err != nil {
+		return errors.New("alipay cert: " + err.Error())
+	}
+	publicKey, ok := alipay.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("alipay cert: not a rsa public key")
+	}
+	rootSN, err := AliRootCertSN(rootCert)
+	if err != nil {
+		return err
+	}
+	ac.AppCertSN = AliCertSN(app)
+	ac.AlipayCertSN = AliCertSN(alipay)
+	ac.AlipayRootCertSN = rootSN
+	ac.PublicKey = publicKey
+	return nil
+}
+
+// AliCertSN 证书序列号: md5(签发者DN + 十进制序列号)
+func AliCertSN(cert *x509.Certificate) string {
+	sum := md5.Sum([]byte(cert.Issuer.String() + cert.SerialNumber.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// AliRootCertSN 根证书序列号: 根证书文件中RSA签名的证书序列号以_连接
+func AliRootCertSN(rootCert []byte) (string, error) {
+	var sns []string
+	for {
+		var block *pem.Block
+		block, rootCert = pem.Decode(rootCert)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", errors.New("alipay root cert: " + err.Error())
+		}
+		switch cert.SignatureAlgorithm {
+		case x509.SHA1WithRSA, x509.SHA256WithRSA:
+			sns = append(sns, AliCertSN(cert))
+		}
+	}
+	if len(sns) == 0 {
+		return "", errors.New("alipay root cert: no rsa certificate")
+	}
+	return strings.Join(sns, "_"), nil
+}
+
+func parseCertPEM(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid certificate pem")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
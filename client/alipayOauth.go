@@ -12,7 +12,6 @@ import (
 	"net/url"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/sulrex/gopay/util"
 )
@@ -88,7 +87,7 @@ func (t *AliOauth) GetUserAccessToken(code string) (result AliOauthToken, err er
 	m["format"] = "JSON"
 	m["charset"] = "utf-8"
 	m["sign_type"] = "RSA2"
-	m["timestamp"] = time.Now().Format("2006-01-02 15:04:05")
+	m["timestamp"] = util.Now().Format("2006-01-02 15:04:05")
 	m["version"] = "1.0"
 	m["grant_type"] = "authorization_code"
 	m["code"] = code
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+// 支付宝收款方标识类型
+const (
+	AliPayeeUserID  = "ALIPAY_USER_ID"  // 支付宝用户号, 2088开头
+	AliPayeeLogonID = "ALIPAY_LOGON_ID" // 支付宝登录号(手机号或邮箱), 须填写收款方姓名
+)
+
+// 支付宝转账status到统一状态
+var aliTransferStates = map[string]common.TransferStatus{
+	"SUCCESS":  common.TransferSuccess,
+	"DEALING":  common.TransferProcessing,
+	"WAIT_PAY": common.TransferProcessing,
+	"INIT":     common.TransferProcessing,
+	"FAIL":     common.TransferFailed,
+	"CLOSED":   common.TransferFailed,
+	"REFUND":   common.TransferBankFail, // 退票
+}
+
+// aliTransferStatus 转为统一状态, 无法识别(含为空)时按处理中, 由调用方查询确认
+func aliTransferStatus(status string) common.TransferStatus {
+	if s, ok := aliTransferStates[status]; ok {
+		return s
+	}
+	return common.TransferProcessing
+}
+
+// Transfer 单笔转账到支付宝账户(alipay.fund.trans.uni.transfer), 需使用公钥证书模式.
+// out_biz_no保证同一单号只转账一次, 通信失败或支付宝系统繁忙时返回ErrTransferUnknown,
+// 此时用原单号原参数重试或调用QueryTransfer确认
+func (ac *AliAppClient) Transfer(ctx context.Context, t *common.AliTransfer) (*common.TransferResult, error) {
+	if ac.AppCertSN == "" {
+		return nil, errors.New("alipay transfer: certificate mode is required, see LoadCerts")
+	}
+	if t.TradeNum == "" || t.PayeeID == "" || t.Title == "" || t.Amount <= 0 {
+		return nil, errors.New("alipay transfer: TradeNum, PayeeID, Title and a positive Amount are required")
+	}
+	payeeType := t.PayeeType
+	if payeeType == "" {
+		payeeType = AliPayeeUserID
+	}
+	if payeeType == AliPayeeLogonID && t.PayeeName == "" {
+		return nil, errors.New("alipay transfer: PayeeName is required for ALIPAY_LOGON_ID")
+	}
+	payee := map[string]string{"identity": t.PayeeID, "identity_type": payeeType}
+	if t.PayeeName != "" {
+		payee["name"] = t.PayeeName
+	}
+	var bizContent = make(map[string]interface{})
+	bizContent["out_biz_no"] = t.TradeNum
+	bizContent["trans_amount"] = formatMinorAmount(t.Amount, constant.CNY)
+	bizContent["product_code"] = "TRANS_ACCOUNT_NO_PWD"
+	bizContent["biz_scene"] = "DIRECT_TRANSFER"
+	bizContent["order_title"] = t.Title
+	bizContent["payee_info"] = payee
+	if t.Remark != "" {
+		bizContent["remark"] = t.Remark
+	}
+
+	var re common.AliTransferResponse
+	err := ac.fundAPI().do(ctx, "alipay.fund.trans.uni.transfer", bizContent, &re)
+	if err != nil {
+		return nil, aliTransferError(err)
+	}
+	result := &common.TransferResult{
+		TradeNum:      re.OutBizNo,
+		PaymentNo:     re.OrderID,
+		Status:        aliTransferStatus(re.Status),
+		ProviderState: re.Status,
+		Amount:        t.Amount,
+		Raw:           re,
+	}
+	if re.Status == "SUCCESS" && re.TransDate != "" {
+		result.PaidAt, err = time.ParseInLocation("2006-01-02 15:04:05", re.TransDate, chinaZone)
+		if err != nil {
+			return nil, errors.New("trans_date: " + err.Error())
+		}
+	}
+	return result, nil
+}
+
+// QueryTransfer 按商户转账单号查询转账(alipay.fund.trans.common.query)
+func (ac *AliAppClient) QueryTransfer(ctx context.Context, tradeNum string) (*common.TransferResult, error) {
+	if ac.AppCertSN == "" {
+		return nil, errors.New("alipay transfer: certificate mode is required, see LoadCerts")
+	}
+	var bizContent = make(map[string]interface{})
+	bizContent["out_biz_no"] = tradeNum
+	bizContent["product_code"] = "TRANS_ACCOUNT_NO_PWD"
+	bizContent["biz_scene"] = "DIRECT_TRANSFER"
+
+	var re common.AliTransferQueryResponse
+	err := ac.fundAPI().do(ctx, "alipay.fund.trans.common.query", bizContent, &re)
+	if err != nil {
+		return nil, err
+	}
+	result := &common.TransferResult{
+		TradeNum:      re.OutBizNo,
+		PaymentNo:     re.OrderID,
+		Status:        aliTransferStatus(re.Status),
+		ProviderState: re.Status,
+		Reason:        re.FailReason,
+		Raw:           re,
+	}
+	decimals := currencyDecimals(constant.CNY)
+	if re.TransAmount != "" {
+		result.Amount, err = util.ParseAmount(re.TransAmount, decimals)
+		if err != nil {
+			return nil, errors.New("trans_amount: " + err.Error())
+		}
+	}
+	if re.OrderFee != "" {
+		result.Fee, err = util.ParseAmount(re.OrderFee, decimals)
+		if err != nil {
+			return nil, errors.New("order_fee: " + err.Error())
+		}
+	}
+	if re.Status == "SUCCESS" && re.PayDate != "" {
+		result.PaidAt, err = time.ParseInLocation("2006-01-02 15:04:05", re.PayDate, chinaZone)
+		if err != nil {
+			return nil, errors.New("pay_date: " + err.Error())
+		}
+	}
+	return result, nil
+}
+
+// QueryBalance 查询支付宝账户余额(alipay.fund.account.query), userID为空时查询SellerID
+func (ac *AliAppClient) QueryBalance(ctx context.Context, userID string) (*common.AccountBalance, error) {
+	if ac.AppCertSN == "" {
+		return nil, errors.New("alipay transfer: certificate mode is required, see LoadCerts")
+	}
+	if userID == "" {
+		userID = ac.SellerID
+	}
+	var bizContent = make(map[string]interface{})
+	bizContent["alipay_user_id"] = userID
+	bizContent["account_type"] = "ACCTRANS_ACCOUNT"
+
+	var re common.AliAccountQueryResponse
+	err := ac.fundAPI().do(ctx, "alipay.fund.account.query", bizContent, &re)
+	if err != nil {
+		return nil, err
+	}
+	decimals := currencyDecimals(constant.CNY)
+	balance := &common.AccountBalance{Raw: re}
+	balance.Available, err = util.ParseAmount(re.AvailableAmount, decimals)
+	if err != nil {
+		return nil, errors.New("available_amount: " + err.Error())
+	}
+	if re.FreezeAmount != "" {
+		balance.Freeze, err = util.ParseAmount(re.FreezeAmount, decimals)
+		if err != nil {
+			return nil, errors.New("freeze_amount: " + err.Error())
+		}
+	}
+	return balance, nil
+}
+
+// aliTransferError 业务失败原样返回, 通信失败或系统繁忙时结果未知
+func aliTransferError(err error) error {
+	var aliErr *AliError
+	if errors.As(err, &aliErr) && aliErr.Code != "20000" && aliErr.SubCode != "SYSTEM_ERROR" {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrTransferUnknown, err)
+}
@@ -1,18 +1,16 @@
 package client
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"net/url"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/sulrex/gopay/common"
 )
@@ -26,6 +24,9 @@ type AliWebClient struct {
 	PrivateKey    *rsa.PrivateKey // 私钥
 	PublicKey     *rsa.PublicKey  // 公钥
 	InsideSandbox bool            // 沙箱阶段
+	AESKey        string          // AES密钥(base64), 设置后biz_content加密传输
+
+	SettleCurrency string // 结算币种, 跨境商户使用
 }
 
 // InitAliWebClient ..
@@ -48,29 +49,51 @@ func (ac *AliWebClient) GateWay() string {
 
 // Pay 实现支付下单接口
 func (ac *AliWebClient) Pay(charge *common.Charge) (map[string]string, error) {
-	m := make(map[string]string)
-	m["app_id"] = ac.AppID
-	m["method"] = "alipay.trade.wap.pay"
-	m["return_url"] = charge.CallbackURL
-	m["charset"] = "utf-8"
-	m["sign_type"] = "RSA2"
-	m["timestamp"] = time.Now().Format("2006-01-02 15:04:05")
-	m["version"] = "1.0"
-	m["notify_url"] = ac.CallbackURL
-	biz, err := json.Marshal(map[string]string{
+	bizContent := map[string]interface{}{
 		"subject":      charge.Describe,
 		"out_trade_no": charge.TradeNum,
-		"total_amount": AliyunMoneyFeeToString(charge.MoneyFee),
 		"product_code": "QUICK_WAP_WAY",
-	})
+	}
+	err := aliAmountParams(charge, ac.SettleCurrency, bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	err = aliExpireParams(charge, bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	if len(charge.Metadata) > 0 {
+		bizContent["passback_params"], err = aliPassbackParams(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	err = aliGoodsParams(charge, bizContent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	m, err := ac.openAPI().params("alipay.trade.wap.pay", bizContent)
 	if err != nil {
-		return map[string]string{}, errors.New("Json Marshal " + err.Error())
+		return map[string]string{}, err
 	}
-	m["biz_content"] = string(biz)
+	m["return_url"] = charge.CallbackURL
+	m["notify_url"] = ac.CallbackURL
 	m["sign"] = ac.GenSign(m)
 	return map[string]string{"url": ac.ToURL(ac.GateWay(), m)}, nil
 }
 
+// openAPI 开放平台请求配置
+func (ac *AliWebClient) openAPI() aliOpenAPI {
+	return aliOpenAPI{
+		appID:     ac.AppID,
+		gateway:   ac.GateWay(),
+		signType:  "RSA2",
+		aesKey:    ac.AESKey,
+		genSign:   ac.GenSign,
+		checkSign: ac.CheckSign,
+	}
+}
+
 // ToURL 生成URL
 func (ac *AliWebClient) ToURL(payURL string, m map[string]string) string {
 	var buf []string
@@ -80,16 +103,19 @@ func (ac *AliWebClient) ToURL(payURL string, m map[string]string) string {
 	return fmt.Sprintf("%s?%s", payURL, strings.Join(buf, "&"))
 }
 
-// QueryOrder 订单查询 (待处理为新接口)
-func (ac *AliWebClient) QueryOrder(outTradeNo string) (common.AliWebQueryResult, error) {
-	var m = make(map[string]string)
-	m["service"] = "single_trade_query"
-	// m["partner"] = ac.PartnerID
-	m["_input_charset"] = "utf-8"
-	m["out_trade_no"] = outTradeNo
-	m["sign"] = ac.GenSign(m)
-	m["sign_type"] = "RSA"
-	return GetAlipay(ToURL(ac.GateWay(), m))
+// QueryOrder 按商户订单号查询订单
+func (ac *AliWebClient) QueryOrder(outTradeNo string) (common.AliWebAppQueryResult, error) {
+	return ac.QueryTrade(context.Background(), common.AliTradeQuery{OutTradeNo: outTradeNo})
+}
+
+// QueryTrade 按支付宝交易号或商户订单号查询订单
+func (ac *AliWebClient) QueryTrade(ctx context.Context, q common.AliTradeQuery) (common.AliWebAppQueryResult, error) {
+	return ac.openAPI().queryTrade(ctx, q)
+}
+
+// Query 查询订单, 返回统一的查询结果
+func (ac *AliWebClient) Query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	return ac.openAPI().query(ctx, tradeNum)
 }
 
 // GenSign 产生签名
@@ -119,22 +145,10 @@ func (ac *AliWebClient) GenSign(m map[string]string) string {
 
 // CheckSign 检测签名
 func (ac *AliWebClient) CheckSign(signData, sign string) error {
+	return ac.VerifySign(AliSignTypeRSA2, signData, sign)
+}
 
-	signByte, err := base64.StdEncoding.DecodeString(sign)
-	if err != nil {
-		return err
-	}
-	s := sha256.New()
-	_, err = s.Write([]byte(signData))
-	if err != nil {
-		return err
-	}
-
-	hash := s.Sum(nil)
-	err = rsa.VerifyPKCS1v15(ac.PublicKey, crypto.SHA256, hash, signByte)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// VerifySign 按指定的签名类型(通知中的sign_type)检测签名
+func (ac *AliWebClient) VerifySign(signType, signData, sign string) error {
+	return aliVerify(ac.PublicKey, signType, signData, sign)
 }
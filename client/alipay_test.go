@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestAliEncrypt(t *testing.T) {
+	aesKey := "aa4BtZ4tspm2wnXLb1ThQA=="
+	content := `{"out_trade_no":"20150320010101001"}`
+	encrypted, err := AliEncrypt(aesKey, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := AliDecrypt(aesKey, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != content {
+		t.Fatalf("got %s, want %s", plain, content)
+	}
+}
+
+func TestAliParseEncryptedResponse(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alipay := &AliWebClient{PrivateKey: key, PublicKey: &key.PublicKey}
+	aesKey := "aa4BtZ4tspm2wnXLb1ThQA=="
+	a := aliOpenAPI{aesKey: aesKey, checkSign: alipay.CheckSign}
+
+	encrypted, _ := AliEncrypt(aesKey, `{"code":"10000","msg":"Success","out_trade_no":"6823789339978248","trade_status":"TRADE_SUCCESS"}`)
+	content, _ := json.Marshal(encrypted)
+	// 对密文(含引号)签名
+	sign := signRSA2(t, key, string(content))
+	body := fmt.Sprintf(`{"alipay_trade_query_response":%s,"sign":"%s"}`, content, sign)
+
+	var re struct {
+		OutTradeNo  string `json:"out_trade_no"`
+		TradeStatus string `json:"trade_status"`
+	}
+	if err := a.parseResponse("alipay.trade.query", []byte(body), &re); err != nil {
+		t.Fatal(err)
+	}
+	if re.OutTradeNo != "6823789339978248" || re.TradeStatus != "TRADE_SUCCESS" {
+		t.Fatalf("unexpected result %+v", re)
+	}
+
+	body = fmt.Sprintf(`{"alipay_trade_query_response":%s,"sign":"%s"}`, content, signRSA2(t, key, "other"))
+	if err := a.parseResponse("alipay.trade.query", []byte(body), &re); err == nil {
+		t.Fatal("parseResponse accepted bad sign")
+	}
+}
+
+func signRSA2(t *testing.T, key *rsa.PrivateKey, data string) string {
+	hash := sha256.Sum256([]byte(data))
+	signByte, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signByte)
+}
+
+func TestAliTransferStatus(t *testing.T) {
+	cases := map[string]common.TransferStatus{
+		"SUCCESS": common.TransferSuccess,
+		"FAIL":    common.TransferFailed,
+		"REFUND":  common.TransferBankFail,
+		"DEALING": common.TransferProcessing,
+		"UNKNOWN": common.TransferProcessing,
+		"":        common.TransferProcessing,
+	}
+	for status, want := range cases {
+		if got := aliTransferStatus(status); got != want {
+			t.Errorf("aliTransferStatus(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestAliTransferUnsignedError(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := `{"code":"40004","msg":"Business Failed","sub_code":"PAYEE_NOT_EXIST","sub_msg":"收款账号不存在"}`
+	var body string
+	ac := &AliAppClient{
+		AppID:      "2016000000000000",
+		PrivateKey: key,
+		PublicKey:  &key.PublicKey,
+		AppCertSN:  "app-cert-sn",
+		FundClient: &HTTPSClient{Client: http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+		})}},
+	}
+	transfer := &common.AliTransfer{TradeNum: "T1", PayeeID: "2088000000000000", Title: "test", Amount: 100}
+
+	// 证书模式下未签名或签名错误的错误应答不可信, 结果未知
+	for _, sign := range []string{"", signRSA2(t, key, "other")} {
+		body = fmt.Sprintf(`{"alipay_fund_trans_uni_transfer_response":%s,"sign":"%s"}`, content, sign)
+		_, err = ac.Transfer(context.Background(), transfer)
+		if !errors.Is(err, ErrTransferUnknown) {
+			t.Errorf("sign %q: got %v, want ErrTransferUnknown", sign, err)
+		}
+	}
+
+	body = fmt.Sprintf(`{"alipay_fund_trans_uni_transfer_response":%s,"sign":"%s"}`, content, signRSA2(t, key, content))
+	_, err = ac.Transfer(context.Background(), transfer)
+	var aliErr *AliError
+	if !errors.As(err, &aliErr) || errors.Is(err, ErrTransferUnknown) || aliErr.SubCode != "PAYEE_NOT_EXIST" {
+		t.Errorf("signed error: got %v, want *AliError PAYEE_NOT_EXIST", err)
+	}
+}
+
+func TestAliSignType(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]string{"app_id": "2016000000000000", "method": "alipay.trade.query"}
+	signData := "app_id=2016000000000000&method=alipay.trade.query"
+	for _, signType := range []string{AliSignTypeRSA, AliSignTypeRSA2} {
+		ac := &AliAppClient{PrivateKey: key, PublicKey: &key.PublicKey, SignType: signType}
+		sign := ac.GenSign(m)
+		if err := ac.VerifySign(signType, signData, sign); err != nil {
+			t.Errorf("%s: VerifySign: %v", signType, err)
+		}
+		other := AliSignTypeRSA2
+		if signType == AliSignTypeRSA2 {
+			other = AliSignTypeRSA
+		}
+		if err := ac.VerifySign(other, signData, sign); err == nil {
+			t.Errorf("%s: VerifySign accepted sign as %s", signType, other)
+		}
+		ac.CheckSign(signData, sign)
+	}
+
+	ac := &AliAppClient{PrivateKey: key, PublicKey: &key.PublicKey}
+	defer func() {
+		if recover() == nil {
+			t.Error("CheckSign did not panic on bad sign")
+		}
+	}()
+	ac.CheckSign(signData, signRSA2(t, key, "other"))
+}
+
+func TestAliCertSN(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与支付宝根证书相同的签发者DN, 期望值为md5("CN=Ant Financial Certification Authority R1,OU=Certification Authority,O=Ant Financial,C=CN623986281")
+	name := pkix.Name{
+		CommonName:         "Ant Financial Certification Authority R1",
+		OrganizationalUnit: []string{"Certification Authority"},
+		Organization:       []string{"Ant Financial"},
+		Country:            []string{"CN"},
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(623986281),
+		Subject:               name,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+	}
+	rsaDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根证书文件中的非RSA证书不参与计算
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(2)
+	tmpl.SignatureAlgorithm = x509.ECDSAWithSHA256
+	ecDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ecKey.PublicKey, ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPEM := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ecDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rsaDER})...)
+
+	want := "2710a32cf84087f5bb0c56543ba0d970"
+	cert, _ := x509.ParseCertificate(rsaDER)
+	if got := AliCertSN(cert); got != want {
+		t.Errorf("AliCertSN = %s, want %s", got, want)
+	}
+	if got, err := AliRootCertSN(rootPEM); err != nil || got != want {
+		t.Errorf("AliRootCertSN = %s, %v, want %s", got, err, want)
+	}
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+// BillIterator 逐条读取对账单, 不把整个账单读入内存. 用法同bufio.Scanner:
+//
+//	for it.Next() {
+//		r := it.Record()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+//	summary := it.Summary()
+type BillIterator struct {
+	p       billParser
+	closers closers
+	record  *common.BillRecord
+	err     error
+	done    bool
+}
+
+// billParser 各渠道账单格式的解析, 读完时next返回io.EOF
+type billParser interface {
+	next() (*common.BillRecord, error)
+	summary() *common.BillSummary
+}
+
+// Next 读取下一笔记录, 读完或出错时返回false
+func (it *BillIterator) Next() bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	it.record, it.err = it.p.next()
+	if it.err == io.EOF {
+		it.err = nil
+		it.done = true
+	}
+	return it.err == nil && !it.done
+}
+
+// Record 当前记录
+func (it *BillIterator) Record() *common.BillRecord {
+	return it.record
+}
+
+// Summary 账单汇总, 微信账单在Next返回false后可用, 没有汇总时为nil
+func (it *BillIterator) Summary() *common.BillSummary {
+	return it.p.summary()
+}
+
+// Err 读取过程中的错误
+func (it *BillIterator) Err() error {
+	return it.err
+}
+
+// Close 关闭下载连接, 删除临时文件
+func (it *BillIterator) Close() error {
+	err := it.closers.close()
+	it.closers = nil
+	return err
+}
+
+// closers 账单读取完后需要关闭的连接和文件, 返回第一个错误
+type closers []io.Closer
+
+func (cs closers) close() error {
+	var err error
+	for _, c := range cs {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// billFields 一行账单按表头名取值, 记录第一个解析错误
+type billFields struct {
+	m   map[string]string
+	err error
+}
+
+// str 按表头名取值, 多个名字时取第一个存在的(不同版本账单表头不同)
+func (f *billFields) str(names ...string) string {
+	for _, n := range names {
+		if v, ok := f.m[n]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (f *billFields) amount(decimals int, names ...string) int64 {
+	s := f.str(names...)
+	if s == "" || f.err != nil {
+		return 0
+	}
+	n, err := util.ParseAmount(s, decimals)
+	if err != nil {
+		f.err = errors.New(names[0] + ": " + err.Error())
+	}
+	return n
+}
+
+func (f *billFields) count(names ...string) int64 {
+	s := f.str(names...)
+	if s == "" || f.err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		f.err = errors.New(names[0] + ": " + err.Error())
+	}
+	return n
+}
+
+// fee 手续费精确到小数点后多位, 按最小货币单位四舍五入
+func (f *billFields) fee(decimals int, names ...string) int64 {
+	const feeDecimals = 6
+	s := f.str(names...)
+	if s == "" || f.err != nil {
+		return 0
+	}
+	n, err := util.ParseAmount(s, feeDecimals)
+	if err != nil {
+		f.err = errors.New(names[0] + ": " + err.Error())
+		return 0
+	}
+	div := int64(1)
+	for i := decimals; i < feeDecimals; i++ {
+		div *= 10
+	}
+	if n < 0 {
+		return -((-n + div/2) / div)
+	}
+	return (n + div/2) / div
+}
+
+func (f *billFields) time(names ...string) time.Time {
+	s := f.str(names...)
+	if s == "" || f.err != nil {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, chinaZone)
+	if err != nil {
+		f.err = errors.New(names[0] + ": " + err.Error())
+	}
+	return t
+}
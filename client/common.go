@@ -2,34 +2,42 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
+	"net"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
 )
 
-// WechatGenSign 微信签名
-func WechatGenSign(key string, m map[string]string) (string, error) {
-	var signData []string
-	for k, v := range m {
-		if v != "" && k != "sign" && k != "key" {
-			signData = append(signData, fmt.Sprintf("%s=%s", k, v))
-		}
+// chargeClientIP 取付款人IP, 未传时退回本机IP
+func chargeClientIP(charge *common.Charge) (string, error) {
+	if charge.ClientIP == "" {
+		return util.LocalIP(), nil
 	}
+	ip := net.ParseIP(charge.ClientIP)
+	if ip == nil {
+		return "", errors.New("invalid client ip: " + charge.ClientIP)
+	}
+	return ip.String(), nil
+}
 
-	sort.Strings(signData)
-	signStr := strings.Join(signData, "&")
-	signStr = signStr + "&key=" + key
-
+// WechatGenSign 微信签名
+func WechatGenSign(key string, m map[string]string) (string, error) {
 	c := md5.New()
-	_, err := c.Write([]byte(signStr))
+	_, err := c.Write([]byte(wechatSignContent(key, m)))
 	if err != nil {
 		return "", errors.New("WechatGenSign md5.Write: " + err.Error())
 	}
@@ -40,6 +48,30 @@ func WechatGenSign(key string, m map[string]string) (string, error) {
 	return strings.ToUpper(fmt.Sprintf("%x", signByte)), nil
 }
 
+// WechatGenHMACSign 微信HMAC-SHA256签名, 请求需带sign_type=HMAC-SHA256
+func WechatGenHMACSign(key string, m map[string]string) (string, error) {
+	h := hmac.New(sha256.New, []byte(key))
+	_, err := h.Write([]byte(wechatSignContent(key, m)))
+	if err != nil {
+		return "", errors.New("WechatGenHMACSign hmac.Write: " + err.Error())
+	}
+	return strings.ToUpper(fmt.Sprintf("%x", h.Sum(nil))), nil
+}
+
+// wechatSignContent 按key排序拼接非空参数, 最后拼上商户密钥
+func wechatSignContent(key string, m map[string]string) string {
+	var signData []string
+	for k, v := range m {
+		if v != "" && k != "sign" && k != "key" {
+			signData = append(signData, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+
+	sort.Strings(signData)
+	signStr := strings.Join(signData, "&")
+	return signStr + "&key=" + key
+}
+
 // TruncatedText ..
 func TruncatedText(data string, length int) string {
 	data = FilterTheSpecialSymbol(data)
@@ -72,17 +104,14 @@ func FilterTheSpecialSymbol(data string) string {
 
 // PostWechat 对微信下订单或者查订单
 func PostWechat(url string, data map[string]string) (common.WeChatQueryResult, error) {
-	var xmlRe common.WeChatQueryResult
-	buf := bytes.NewBufferString("")
+	return postWechat(context.Background(), url, data)
+}
 
-	for k, v := range data {
-		buf.WriteString(fmt.Sprintf("<%s><![CDATA[%s]]></%s>", k, v, k))
-	}
-	xmlStr := fmt.Sprintf("<xml>%s</xml>", buf.String())
-	// fmt.Println(xmlStr)
-	re, err := HTTPSC.PostData(url, "text/xml;charset=UTF-8", xmlStr)
+func postWechat(ctx context.Context, url string, data map[string]string) (common.WeChatQueryResult, error) {
+	var xmlRe common.WeChatQueryResult
+	re, err := postWechatXML(ctx, url, data)
 	if err != nil {
-		return xmlRe, errors.New("HTTPSC.PostData: " + err.Error())
+		return xmlRe, err
 	}
 
 	err = xml.Unmarshal(re, &xmlRe)
@@ -102,19 +131,33 @@ func PostWechat(url string, data map[string]string) (common.WeChatQueryResult, e
 	return xmlRe, nil
 }
 
-// GetAlipay 对支付宝者查订单
-func GetAlipay(url string) (common.AliWebQueryResult, error) {
-	var xmlRe common.AliWebQueryResult
+// postWechatXML 提交xml数据到微信, 返回原始响应
+func postWechatXML(ctx context.Context, url string, data map[string]string) ([]byte, error) {
+	resp, err := doWechatXML(ctx, HTTPSC, url, data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
 
-	re, err := HTTPSC.GetData(url)
+// doWechatXML 用hc提交xml数据到微信, 调用方需关闭resp.Body
+func doWechatXML(ctx context.Context, hc *HTTPSClient, url string, data map[string]string) (*http.Response, error) {
+	buf := bytes.NewBufferString("")
+	for k, v := range data {
+		buf.WriteString(fmt.Sprintf("<%s><![CDATA[%s]]></%s>", k, v, k))
+	}
+	xmlStr := fmt.Sprintf("<xml>%s</xml>", buf.String())
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(xmlStr))
 	if err != nil {
-		return xmlRe, errors.New("HTTPSC.PostData: " + err.Error())
+		return nil, err
 	}
-	err = xml.Unmarshal(re, &xmlRe)
+	req.Header.Set("Content-Type", "text/xml;charset=UTF-8")
+	resp, err := hc.Do(req)
 	if err != nil {
-		return xmlRe, errors.New("xml.Unmarshal: " + err.Error())
+		return nil, errors.New("HTTPSC.Do: " + err.Error())
 	}
-	return xmlRe, nil
+	return resp, nil
 }
 
 // GetAlipayApp 对支付宝者查订单
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+)
+
+// 没有辅币单位的币种, 金额不乘100
+var zeroDecimalCurrencies = map[string]bool{
+	constant.JPY: true,
+	constant.KRW: true,
+	constant.VND: true,
+	constant.IDR: true,
+}
+
+// chargeCurrency 订单币种, 默认人民币
+func chargeCurrency(charge *common.Charge) (string, error) {
+	if charge.Currency == "" {
+		return constant.CNY, nil
+	}
+	return checkCurrency(charge.Currency)
+}
+
+func checkCurrency(currency string) (string, error) {
+	if len(currency) != 3 {
+		return "", errors.New("invalid currency: " + currency)
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return "", errors.New("invalid currency: " + currency)
+		}
+	}
+	return currency, nil
+}
+
+// currencyDecimals 币种金额的小数位数
+func currencyDecimals(currency string) int {
+	if zeroDecimalCurrencies[currency] {
+		return 0
+	}
+	return 2
+}
+
+// minorAmount 金额转为最小货币单位, 人民币为分
+func minorAmount(moneyFee float64, currency string) int64 {
+	return int64(RoundFloat(moneyFee*math.Pow10(currencyDecimals(currency)), 0))
+}
+
+// MinorAmount 金额按币种转为下单时提交的最小货币单位金额, 币种为空时按人民币
+func MinorAmount(moneyFee float64, currency string) int64 {
+	if currency == "" {
+		currency = constant.CNY
+	}
+	return minorAmount(moneyFee, currency)
+}
+
+// majorAmount 金额按币种小数位数格式化, 人民币为元
+func majorAmount(moneyFee float64, currency string) string {
+	d := currencyDecimals(currency)
+	return strconv.FormatFloat(RoundFloat(moneyFee, d), 'f', d, 64)
+}
+
+// formatMinorAmount 最小货币单位金额按币种小数位数格式化, 如1230分为"12.30", 不经过浮点数
+func formatMinorAmount(amount int64, currency string) string {
+	d := currencyDecimals(currency)
+	if amount < 0 {
+		return "-" + formatMinorAmount(-amount, currency)
+	}
+	s := strconv.FormatInt(amount, 10)
+	if d == 0 {
+		return s
+	}
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+	return s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// wechatAmountParams 设置微信total_fee, 非人民币时设置fee_type
+func wechatAmountParams(charge *common.Charge, m map[string]string) error {
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return err
+	}
+	m["total_fee"] = strconv.FormatInt(minorAmount(charge.MoneyFee, currency), 10)
+	if currency != constant.CNY {
+		m["fee_type"] = currency
+	}
+	return nil
+}
+
+// aliAmountParams 设置支付宝total_amount, 非人民币标价时设置trans_currency, 指定结算币种时设置settle_currency
+func aliAmountParams(charge *common.Charge, settleCurrency string, bizContent map[string]interface{}) error {
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return err
+	}
+	bizContent["total_amount"] = majorAmount(charge.MoneyFee, currency)
+	if currency != constant.CNY {
+		bizContent["trans_currency"] = currency
+	}
+	if settleCurrency != "" {
+		settleCurrency, err = checkCurrency(settleCurrency)
+		if err != nil {
+			return err
+		}
+		bizContent["settle_currency"] = settleCurrency
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestAmountParams(t *testing.T) {
+	m := map[string]string{}
+	if err := wechatAmountParams(&common.Charge{MoneyFee: 12.34}, m); err != nil {
+		t.Fatal(err)
+	}
+	if m["total_fee"] != "1234" || m["fee_type"] != "" {
+		t.Fatalf("unexpected wechat params %v", m)
+	}
+	m = map[string]string{}
+	if err := wechatAmountParams(&common.Charge{MoneyFee: 1200, Currency: "JPY"}, m); err != nil {
+		t.Fatal(err)
+	}
+	if m["total_fee"] != "1200" || m["fee_type"] != "JPY" {
+		t.Fatalf("unexpected wechat params %v", m)
+	}
+
+	biz := map[string]interface{}{}
+	if err := aliAmountParams(&common.Charge{MoneyFee: 9.9, Currency: "HKD"}, "USD", biz); err != nil {
+		t.Fatal(err)
+	}
+	if biz["total_amount"] != "9.90" || biz["trans_currency"] != "HKD" || biz["settle_currency"] != "USD" {
+		t.Fatalf("unexpected alipay params %v", biz)
+	}
+
+	if err := wechatAmountParams(&common.Charge{MoneyFee: 1, Currency: "hkd"}, map[string]string{}); err == nil {
+		t.Fatal("wechatAmountParams accepted lowercase currency")
+	}
+	if got := wechatEndpoint(wechatGateWay+"/pay/orderquery", true, true); got != "https://apihk.mch.weixin.qq.com/sandboxnew/pay/orderquery" {
+		t.Fatalf("wechatEndpoint = %s", got)
+	}
+}
+
+func TestFormatMinorAmount(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{1230, "CNY", "12.30"},
+		{5, "CNY", "0.05"},
+		{0, "CNY", "0.00"},
+		{-150, "CNY", "-1.50"},
+		{1200, "JPY", "1200"},
+	}
+	for _, c := range cases {
+		if got := formatMinorAmount(c.amount, c.currency); got != c.want {
+			t.Errorf("formatMinorAmount(%d, %s) = %s, want %s", c.amount, c.currency, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+// 支付宝和微信的时间参数都按北京时间
+var chinaZone = time.FixedZone("CST", 8*3600)
+
+// 订单有效时长限制
+const (
+	wechatMinTimeout = time.Minute
+	aliMinTimeout    = time.Minute
+	aliMaxTimeout    = 15 * 24 * time.Hour
+)
+
+// chargeExpireAt 计算订单失效时间并校验有效时长, 未设置时返回零值
+func chargeExpireAt(charge *common.Charge, min, max time.Duration) (now, expireAt time.Time, err error) {
+	now = util.Now()
+	switch {
+	case !charge.ExpireAt.IsZero():
+		expireAt = charge.ExpireAt
+	case charge.Timeout > 0:
+		expireAt = now.Add(charge.Timeout)
+	case charge.Timeout < 0:
+		return now, expireAt, errors.New("charge timeout must be positive")
+	default:
+		return now, expireAt, nil
+	}
+
+	d := expireAt.Sub(now)
+	if d < min {
+		return now, expireAt, fmt.Errorf("charge expires in %s, less than %s", d, min)
+	}
+	if max > 0 && d > max {
+		return now, expireAt, fmt.Errorf("charge expires in %s, more than %s", d, max)
+	}
+	return now, expireAt, nil
+}
+
+// wechatExpireParams 设置微信time_start/time_expire
+func wechatExpireParams(charge *common.Charge, m map[string]string) error {
+	now, expireAt, err := chargeExpireAt(charge, wechatMinTimeout, 0)
+	if err != nil || expireAt.IsZero() {
+		return err
+	}
+	m["time_start"] = now.In(chinaZone).Format("20060102150405")
+	m["time_expire"] = expireAt.In(chinaZone).Format("20060102150405")
+	return nil
+}
+
+// aliExpireParams 设置支付宝超时参数, 指定失效时间用time_expire, 否则用timeout_express
+func aliExpireParams(charge *common.Charge, bizContent map[string]interface{}) error {
+	_, expireAt, err := chargeExpireAt(charge, aliMinTimeout, aliMaxTimeout)
+	if err != nil || expireAt.IsZero() {
+		return err
+	}
+	if !charge.ExpireAt.IsZero() {
+		bizContent["time_expire"] = expireAt.In(chinaZone).Format("2006-01-02 15:04:05")
+		return nil
+	}
+	// timeout_express最小单位为分钟, 向下取整
+	bizContent["timeout_express"] = fmt.Sprintf("%dm", int64(charge.Timeout/time.Minute))
+	return nil
+}
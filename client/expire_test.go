@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+func TestExpireParams(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	util.SetClock(func() time.Time { return now })
+	defer util.SetClock(nil)
+
+	m := map[string]string{}
+	if err := wechatExpireParams(&common.Charge{Timeout: 30 * time.Minute}, m); err != nil {
+		t.Fatal(err)
+	}
+	if m["time_start"] != "20200102110405" || m["time_expire"] != "20200102113405" {
+		t.Fatalf("unexpected wechat params %v", m)
+	}
+
+	biz := map[string]interface{}{}
+	if err := aliExpireParams(&common.Charge{Timeout: 90 * time.Minute}, biz); err != nil {
+		t.Fatal(err)
+	}
+	if biz["timeout_express"] != "90m" {
+		t.Fatalf("unexpected alipay params %v", biz)
+	}
+	biz = map[string]interface{}{}
+	if err := aliExpireParams(&common.Charge{ExpireAt: now.Add(time.Hour)}, biz); err != nil {
+		t.Fatal(err)
+	}
+	if biz["time_expire"] != "2020-01-02 12:04:05" {
+		t.Fatalf("unexpected alipay params %v", biz)
+	}
+
+	m = map[string]string{}
+	if err := wechatExpireParams(&common.Charge{}, m); err != nil || len(m) != 0 {
+		t.Fatalf("unexpected params %v, %v", m, err)
+	}
+	for _, charge := range []*common.Charge{
+		{Timeout: 30 * time.Second},
+		{Timeout: 16 * 24 * time.Hour},
+		{ExpireAt: now.Add(-time.Hour)},
+	} {
+		if err := aliExpireParams(charge, map[string]interface{}{}); err == nil {
+			t.Errorf("aliExpireParams accepted %+v", charge)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// checkGoods 校验商品明细
+func checkGoods(charge *common.Charge) error {
+	for i, g := range charge.Goods {
+		if g.ID == "" || g.Name == "" {
+			return fmt.Errorf("goods[%d]: id and name are required", i)
+		}
+		if g.Quantity <= 0 {
+			return fmt.Errorf("goods[%d]: quantity must be positive", i)
+		}
+		if g.Price < 0 {
+			return fmt.Errorf("goods[%d]: price must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// wechatGoodsParams 设置微信单品优惠参数detail, goods_tag, 传商品明细时使用单品优惠版本
+func wechatGoodsParams(charge *common.Charge, m map[string]string) error {
+	if charge.GoodsTag != "" {
+		m["goods_tag"] = charge.GoodsTag
+	}
+	if len(charge.Goods) == 0 {
+		return nil
+	}
+	err := checkGoods(charge)
+	if err != nil {
+		return err
+	}
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return err
+	}
+
+	var goods []map[string]interface{}
+	for _, g := range charge.Goods {
+		goods = append(goods, map[string]interface{}{
+			"goods_id":   g.ID,
+			"goods_name": g.Name,
+			"quantity":   g.Quantity,
+			"price":      minorAmount(g.Price, currency),
+		})
+	}
+	detail, err := json.Marshal(map[string]interface{}{"goods_detail": goods})
+	if err != nil {
+		return errors.New("json.Marshal: " + err.Error())
+	}
+	m["detail"] = string(detail)
+	m["version"] = "1.0"
+	return nil
+}
+
+// wechatV3GoodsParams 设置v3单品优惠参数
+func wechatV3GoodsParams(charge *common.Charge, body map[string]interface{}) error {
+	if charge.GoodsTag != "" {
+		body["goods_tag"] = charge.GoodsTag
+	}
+	if len(charge.Goods) == 0 {
+		return nil
+	}
+	err := checkGoods(charge)
+	if err != nil {
+		return err
+	}
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return err
+	}
+
+	var goods []map[string]interface{}
+	for _, g := range charge.Goods {
+		goods = append(goods, map[string]interface{}{
+			"merchant_goods_id": g.ID,
+			"goods_name":        g.Name,
+			"quantity":          g.Quantity,
+			"unit_price":        minorAmount(g.Price, currency),
+		})
+	}
+	body["detail"] = map[string]interface{}{"goods_detail": goods}
+	return nil
+}
+
+// aliGoodsParams 设置支付宝goods_detail, undiscountable_amount
+func aliGoodsParams(charge *common.Charge, bizContent map[string]interface{}) error {
+	if charge.UndiscountableAmount < 0 || charge.UndiscountableAmount > charge.MoneyFee {
+		return errors.New("undiscountable amount must be between 0 and the order amount")
+	}
+	err := checkGoods(charge)
+	if err != nil {
+		return err
+	}
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return err
+	}
+	if charge.UndiscountableAmount > 0 {
+		bizContent["undiscountable_amount"] = majorAmount(charge.UndiscountableAmount, currency)
+	}
+	if len(charge.Goods) == 0 {
+		return nil
+	}
+
+	var goods []map[string]interface{}
+	for _, g := range charge.Goods {
+		item := map[string]interface{}{
+			"goods_id":   g.ID,
+			"goods_name": g.Name,
+			"quantity":   g.Quantity,
+			"price":      majorAmount(g.Price, currency),
+		}
+		if g.Category != "" {
+			item["goods_category"] = g.Category
+		}
+		goods = append(goods, item)
+	}
+	bizContent["goods_detail"] = goods
+	return nil
+}
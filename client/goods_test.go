@@ -0,0 +1,67 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestGoodsParams(t *testing.T) {
+	charge := &common.Charge{
+		MoneyFee:             10,
+		GoodsTag:             "WXG",
+		UndiscountableAmount: 2,
+		Goods: []common.GoodsItem{
+			{ID: "1001", Name: "iPhone6s 16G", Quantity: 1, Price: 5.28, Category: "phone"},
+		},
+	}
+
+	m := map[string]string{}
+	if err := wechatGoodsParams(charge, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"goods_detail":[{"goods_id":"1001","goods_name":"iPhone6s 16G","price":528,"quantity":1}]}`
+	if m["detail"] != want || m["goods_tag"] != "WXG" || m["version"] != "1.0" {
+		t.Fatalf("unexpected wechat params %v", m)
+	}
+
+	biz := map[string]interface{}{}
+	if err := aliGoodsParams(charge, biz); err != nil {
+		t.Fatal(err)
+	}
+	goods := biz["goods_detail"].([]map[string]interface{})
+	if biz["undiscountable_amount"] != "2.00" || goods[0]["price"] != "5.28" || goods[0]["goods_category"] != "phone" {
+		t.Fatalf("unexpected alipay params %v", biz)
+	}
+
+	charge.Goods[0].Quantity = 0
+	if err := wechatGoodsParams(charge, map[string]string{}); err == nil {
+		t.Fatal("wechatGoodsParams accepted zero quantity")
+	}
+	charge.Goods = nil
+	charge.UndiscountableAmount = 11
+	if err := aliGoodsParams(charge, map[string]interface{}{}); err == nil {
+		t.Fatal("aliGoodsParams accepted undiscountable amount above total")
+	}
+}
+
+func TestParsePromotions(t *testing.T) {
+	r := common.WechatResultData{PromotionDetail: `{"promotion_detail":[{"promotion_id":"109519","scope":"SINGLE","type":"DISCOUNT","amount":5,` +
+		`"goods_detail":[{"goods_id":"1001","quantity":1,"price":528,"discount_amount":5}]}]}`}
+	promotions, err := r.Promotions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promotions) != 1 || promotions[0].GoodsDetail[0].DiscountAmount != 5 {
+		t.Fatalf("unexpected promotions %+v", promotions)
+	}
+
+	ali := common.AliQueryResult{DiscountGoodsDetail: `[{"goods_id":"1001","goods_name":"iPhone6s 16G","discount_amount":"0.05","voucher_id":"2015102600073002039000002D5O"}]`}
+	goods, err := ali.DiscountGoods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goods) != 1 || goods[0].DiscountAmount != "0.05" {
+		t.Fatalf("unexpected discount goods %+v", goods)
+	}
+}
@@ -21,11 +21,14 @@ var (
 	HTTPC *HTTPClient
 	// HTTPSC ..
 	HTTPSC *HTTPSClient
+	// VerifiedHTTPSC 校验服务端证书的https客户端, 资金类接口默认使用
+	VerifiedHTTPSC *HTTPSClient
 )
 
 func init() {
 	HTTPC = &HTTPClient{}
 	HTTPSC = NewHTTPSClient()
+	VerifiedHTTPSC = NewVerifiedHTTPSClient()
 }
 
 // HTTPSClient HTTPS客户端结构
@@ -69,6 +72,49 @@ func NewHTTPSClient() *HTTPSClient {
 	}
 }
 
+// NewVerifiedHTTPSClient 校验服务端证书的https客户端
+func NewVerifiedHTTPSClient() *HTTPSClient {
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	client := http.Client{
+		Transport: tr,
+		Timeout:   15 * time.Second,
+	}
+	return &HTTPSClient{
+		Client: client,
+	}
+}
+
+// NewTLSClient 加载商户证书(apiclient_cert.pem和apiclient_key.pem)的双向TLS客户端,
+// 微信资金账单等需要证书的接口使用
+func NewTLSClient(certFile, keyFile string) (*HTTPSClient, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.New("tls.LoadX509KeyPair: " + err.Error())
+	}
+	return newTLSClient(cert), nil
+}
+
+// NewTLSClientPEM 同NewTLSClient, 证书和私钥为PEM内容
+func NewTLSClientPEM(certPEM, keyPEM []byte) (*HTTPSClient, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.New("tls.X509KeyPair: " + err.Error())
+	}
+	return newTLSClient(cert), nil
+}
+
+func newTLSClient(cert tls.Certificate) *HTTPSClient {
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	tr := &http.Transport{TLSClientConfig: config, Proxy: http.ProxyFromEnvironment}
+	client := http.Client{
+		Transport: tr,
+		Timeout:   15 * time.Second,
+	}
+	return &HTTPSClient{
+		Client: client,
+	}
+}
+
 // PostData 提交post数据
 func (c *HTTPSClient) PostData(url string, contentType string, data string) ([]byte, error) {
 	resp, err := c.Post(url, contentType, strings.NewReader(data))
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// 附加数据长度限制(字节)
+const (
+	wechatAttachMaxLen      = 127
+	aliPassbackParamsMaxLen = 512
+)
+
+// wechatAttach 业务附加数据编码为微信attach
+func wechatAttach(charge *common.Charge) (string, error) {
+	attach := common.EncodeMetadata(charge.Metadata)
+	if len(attach) > wechatAttachMaxLen {
+		return "", fmt.Errorf("charge metadata is %d bytes, wechat attach allows %d", len(attach), wechatAttachMaxLen)
+	}
+	return attach, nil
+}
+
+// aliPassbackParams 业务附加数据编码后再url编码为支付宝passback_params
+func aliPassbackParams(charge *common.Charge) (string, error) {
+	params := url.QueryEscape(common.EncodeMetadata(charge.Metadata))
+	if len(params) > aliPassbackParamsMaxLen {
+		return "", fmt.Errorf("charge metadata is %d bytes, alipay passback_params allows %d", len(params), aliPassbackParamsMaxLen)
+	}
+	return params, nil
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestMetadataLimits(t *testing.T) {
+	charge := &common.Charge{Metadata: map[string]string{"k": strings.Repeat("v", 126)}}
+	if _, err := wechatAttach(charge); err == nil {
+		t.Fatal("wechatAttach accepted more than 127 bytes")
+	}
+	if _, err := aliPassbackParams(charge); err != nil {
+		t.Fatal(err)
+	}
+
+	// 中文经两次url编码后每字15字节
+	charge.Metadata = map[string]string{"k": strings.Repeat("中", 60)}
+	if _, err := aliPassbackParams(charge); err == nil {
+		t.Fatal("aliPassbackParams accepted more than 512 bytes")
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+// 微信trade_state到统一状态
+var wechatTradeStates = map[string]common.TradeState{
+	"SUCCESS":    common.TradeStateSuccess,
+	"REFUND":     common.TradeStateRefunded,
+	"NOTPAY":     common.TradeStateNotPay,
+	"CLOSED":     common.TradeStateClosed,
+	"REVOKED":    common.TradeStateRevoked,
+	"USERPAYING": common.TradeStatePaying,
+	"ACCEPT":     common.TradeStatePaying, // v3已接收, 等待扣款
+	"PAYERROR":   common.TradeStatePayError,
+}
+
+// 支付宝trade_status到统一状态
+var aliTradeStates = map[string]common.TradeState{
+	"WAIT_BUYER_PAY": common.TradeStateNotPay,
+	"TRADE_CLOSED":   common.TradeStateClosed,
+	"TRADE_SUCCESS":  common.TradeStateSuccess,
+	"TRADE_FINISHED": common.TradeStateSuccess,
+}
+
+// wechatQueryResult 微信订单查询结果转为统一结果
+func wechatQueryResult(re common.WeChatQueryResult) (*common.QueryResult, error) {
+	result := &common.QueryResult{
+		TradeNum:      re.OutTradeNO,
+		TransactionID: re.TransactionID,
+		TradeState:    wechatTradeStates[re.TradeState],
+		ProviderState: re.TradeState,
+		Currency:      re.FeeType,
+		TotalFee:      re.TotalFee,
+		PaidFee:       re.CashFee,
+		Payer:         re.OpenID,
+		Raw:           re,
+	}
+	if result.Currency == "" {
+		result.Currency = constant.CNY
+	}
+	if re.TimeEnd != "" {
+		t, err := time.ParseInLocation("20060102150405", re.TimeEnd, chinaZone)
+		if err != nil {
+			return nil, errors.New("time_end: " + err.Error())
+		}
+		result.PaidAt = t
+	}
+	var err error
+	result.Metadata, err = re.Metadata()
+	if err != nil {
+		return nil, errors.New("attach: " + err.Error())
+	}
+	return result, nil
+}
+
+// wechatV3QueryResult 微信v3订单查询结果转为统一结果
+func wechatV3QueryResult(re common.WeChatV3Transaction) (*common.QueryResult, error) {
+	result := &common.QueryResult{
+		TradeNum:      re.OutTradeNo,
+		TransactionID: re.TransactionID,
+		TradeState:    wechatTradeStates[re.TradeState],
+		ProviderState: re.TradeState,
+		Currency:      re.Amount.Currency,
+		TotalFee:      re.Amount.Total,
+		PaidFee:       re.Amount.PayerTotal,
+		Payer:         re.Payer.OpenID,
+		Raw:           re,
+	}
+	if result.Currency == "" {
+		result.Currency = constant.CNY
+	}
+	if re.SuccessTime != "" {
+		t, err := time.Parse(time.RFC3339, re.SuccessTime)
+		if err != nil {
+			return nil, errors.New("success_time: " + err.Error())
+		}
+		result.PaidAt = t
+	}
+	var err error
+	result.Metadata, err = re.Metadata()
+	if err != nil {
+		return nil, errors.New("attach: " + err.Error())
+	}
+	return result, nil
+}
+
+// aliQueryResult 支付宝订单查询结果转为统一结果
+func aliQueryResult(re common.AliWebAppQueryResult) (*common.QueryResult, error) {
+	r := re.AlipayTradeQueryResponse
+	result := &common.QueryResult{
+		TradeNum:      r.OutTradeNo,
+		TransactionID: r.TradeNo,
+		TradeState:    aliTradeStates[r.TradeStatus],
+		ProviderState: r.TradeStatus,
+		Currency:      r.TransCurrency,
+		Payer:         r.BuyerUserID,
+		Raw:           re,
+	}
+	if result.Currency == "" {
+		result.Currency = constant.CNY
+	}
+	if result.Payer == "" {
+		result.Payer = r.BuyerLogonID
+	}
+
+	var err error
+	decimals := currencyDecimals(result.Currency)
+	result.TotalFee, err = util.ParseAmount(r.TotalAmount, decimals)
+	if err != nil {
+		return nil, errors.New("total_amount: " + err.Error())
+	}
+	if r.BuyerPayAmount != "" {
+		result.PaidFee, err = util.ParseAmount(r.BuyerPayAmount, decimals)
+		if err != nil {
+			return nil, errors.New("buyer_pay_amount: " + err.Error())
+		}
+	}
+	if r.SendPayDate != "" {
+		result.PaidAt, err = time.ParseInLocation("2006-01-02 15:04:05", r.SendPayDate, chinaZone)
+		if err != nil {
+			return nil, errors.New("send_pay_date: " + err.Error())
+		}
+	}
+	result.Metadata, err = re.Metadata()
+	if err != nil {
+		return nil, errors.New("passback_params: " + err.Error())
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestWechatV3QueryResult(t *testing.T) {
+	var tx common.WeChatV3Transaction
+	tx.OutTradeNo = "1217752501201407033233368018"
+	tx.TradeState = "REFUND"
+	tx.SuccessTime = "2018-06-08T10:34:56+08:00"
+	tx.Amount.Total = 100
+	tx.Amount.PayerTotal = 90
+	tx.Payer.OpenID = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+	tx.Attach = "shop=1"
+
+	re, err := wechatV3QueryResult(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.TradeState != common.TradeStateRefunded || re.TotalFee != 100 || re.PaidFee != 90 || re.Currency != "CNY" || re.PaidAt.Unix() != 1528425296 || re.Metadata["shop"] != "1" {
+		t.Fatalf("unexpected result %+v", re)
+	}
+
+	tx.TradeState = "SOMETHING_NEW"
+	re, _ = wechatV3QueryResult(tx)
+	if re.TradeState != common.TradeStateUnknown || re.ProviderState != "SOMETHING_NEW" {
+		t.Fatalf("unexpected result %+v", re)
+	}
+}
+
+func TestAliQueryResult(t *testing.T) {
+	var re common.AliWebAppQueryResult
+	re.AlipayTradeQueryResponse.TradeStatus = "TRADE_FINISHED"
+	re.AlipayTradeQueryResponse.TotalAmount = "88.88"
+	re.AlipayTradeQueryResponse.BuyerPayAmount = "8.88"
+	re.AlipayTradeQueryResponse.BuyerLogonID = "159****5620"
+
+	result, err := aliQueryResult(re)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TradeState != common.TradeStateSuccess || result.TotalFee != 8888 || result.PaidFee != 888 || result.Payer != "159****5620" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
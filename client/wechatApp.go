@@ -1,10 +1,10 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/sulrex/gopay/common"
 	"github.com/sulrex/gopay/util"
@@ -24,11 +24,14 @@ func DefaultWechatAppClient() *WechatAppClient {
 
 // WechatAppClient 微信app支付
 type WechatAppClient struct {
-	AppID       string // AppID
-	MchID       string // 商户号ID
-	CallbackURL string // 回调地址
-	Key         string // 密钥
-	PayURL      string // 支付地址
+	AppID         string       // AppID
+	MchID         string       // 商户号ID
+	CallbackURL   string       // 回调地址
+	Key           string       // 密钥
+	PayURL        string       // 支付地址
+	InsideSandbox bool         // 沙箱阶段
+	CrossBorder   bool         // 境外商户, 使用香港接入点
+	CertClient    *HTTPSClient // 商户证书客户端(NewTLSClient), 资金账单等接口使用
 }
 
 // Pay 支付
@@ -39,20 +42,45 @@ func (wc *WechatAppClient) Pay(charge *common.Charge) (map[string]string, error)
 	m["nonce_str"] = util.RandomStr()
 	m["body"] = TruncatedText(charge.Describe, 32)
 	m["out_trade_no"] = charge.TradeNum
-	m["total_fee"] = WechatMoneyFeeToString(charge.MoneyFee)
-	m["spbill_create_ip"] = util.LocalIP()
+	err := wechatAmountParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	clientIP, err := chargeClientIP(charge)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	m["spbill_create_ip"] = clientIP
+	err = wechatExpireParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	if len(charge.Metadata) > 0 {
+		m["attach"], err = wechatAttach(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	err = wechatGoodsParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
 	m["notify_url"] = charge.CallbackURL
 	m["trade_type"] = "APP"
 	m["sign_type"] = "MD5"
 
-	sign, err := WechatGenSign(wc.Key, m)
+	key, err := wc.SignKey()
+	if err != nil {
+		return map[string]string{}, err
+	}
+	sign, err := WechatGenSign(key, m)
 	if err != nil {
 		return map[string]string{}, errors.New("WechatApp.sign: " + err.Error())
 	}
 
 	m["sign"] = sign
 
-	xmlRe, err := PostWechat(wc.PayURL, m)
+	xmlRe, err := PostWechat(wechatEndpoint(wc.PayURL, wc.CrossBorder, wc.InsideSandbox), m)
 	if err != nil {
 		return map[string]string{}, err
 	}
@@ -63,9 +91,9 @@ func (wc *WechatAppClient) Pay(charge *common.Charge) (map[string]string, error)
 	c["prepayid"] = xmlRe.PrepayID
 	c["package"] = "Sign=WXPay"
 	c["noncestr"] = util.RandomStr()
-	c["timestamp"] = fmt.Sprintf("%d", time.Now().Unix())
+	c["timestamp"] = fmt.Sprintf("%d", util.Now().Unix())
 
-	sign2, err := WechatGenSign(wc.Key, c)
+	sign2, err := WechatGenSign(key, c)
 	if err != nil {
 		return map[string]string{}, errors.New("WechatApp.paySign: " + err.Error())
 	}
@@ -76,18 +104,49 @@ func (wc *WechatAppClient) Pay(charge *common.Charge) (map[string]string, error)
 
 // QueryOrder 查询订单
 func (wc *WechatAppClient) QueryOrder(tradeNum string) (common.WeChatQueryResult, error) {
+	return wc.queryOrder(context.Background(), tradeNum)
+}
+
+// Query 查询订单, 返回统一的查询结果
+func (wc *WechatAppClient) Query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	re, err := wc.queryOrder(ctx, tradeNum)
+	if err != nil {
+		return nil, err
+	}
+	return wechatQueryResult(re)
+}
+
+func (wc *WechatAppClient) queryOrder(ctx context.Context, tradeNum string) (common.WeChatQueryResult, error) {
 	var m = make(map[string]string)
 	m["appid"] = wc.AppID
 	m["mch_id"] = wc.MchID
 	m["out_trade_no"] = tradeNum
 	m["nonce_str"] = util.RandomStr()
+	m["version"] = "1.0" // 返回单品优惠信息
 
-	sign, err := WechatGenSign(wc.Key, m)
+	key, err := wc.signKey(ctx)
+	if err != nil {
+		return common.WeChatQueryResult{}, err
+	}
+	sign, err := WechatGenSign(key, m)
 	if err != nil {
 		return common.WeChatQueryResult{}, err
 	}
 
 	m["sign"] = sign
 
-	return PostWechat("https://api.mch.weixin.qq.com/pay/orderquery", m)
+	return postWechat(ctx, wechatEndpoint(wechatGateWay+"/pay/orderquery", wc.CrossBorder, wc.InsideSandbox), m)
+}
+
+// SignKey 签名密钥, 沙箱阶段为沙箱密钥
+func (wc *WechatAppClient) SignKey() (string, error) {
+	return wc.signKey(context.Background())
+}
+
+// signKey 签名密钥, 沙箱阶段获取沙箱密钥时使用ctx
+func (wc *WechatAppClient) signKey(ctx context.Context) (string, error) {
+	if wc.InsideSandbox {
+		return WechatSandboxSignKey(ctx, wc.MchID, wc.Key, wc.CrossBorder)
+	}
+	return wc.Key, nil
 }
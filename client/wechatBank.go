@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+// wechatRiskGateWay 获取RSA公钥的域名与支付接口不同
+const wechatRiskGateWay = "https://fraud.mch.weixin.qq.com"
+
+// bankKeyTTL 获取的RSA公钥缓存时间, 过期后重新获取以应对微信更换公钥
+const bankKeyTTL = 24 * time.Hour
+
+// wechatEncryptError 付款到银行卡时微信无法解密enc_bank_no或enc_true_name的错误码
+const wechatEncryptError = "ENCRYPT_ERROR"
+
+// TransferBank 企业付款到银行卡(mmpaysptrans/pay_bank), 卡号和姓名用微信RSA公钥加密.
+// 加密和签名只做一次, 重试时提交相同的内容; 受理成功后用QueryBankTransfer查询是否到账
+func (wc *WechatTransferClient) TransferBank(ctx context.Context, t *common.BankTransfer) (*common.WeChatBankTransferResult, error) {
+	if wc.CertClient == nil {
+		return nil, errors.New("wechat transfer: CertClient is required")
+	}
+	if t.TradeNum == "" || t.BankNo == "" || t.TrueName == "" || t.BankCode == "" || t.Amount <= 0 {
+		return nil, errors.New("wechat bank transfer: TradeNum, BankNo, TrueName, BankCode and a positive Amount are required")
+	}
+	key, err := wc.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encBankNo, err := wechatEncrypt(key, t.BankNo)
+	if err != nil {
+		return nil, err
+	}
+	encTrueName, err := wechatEncrypt(key, t.TrueName)
+	if err != nil {
+		return nil, err
+	}
+	var m = make(map[string]string)
+	m["mch_id"] = wc.MchID
+	m["partner_trade_no"] = t.TradeNum
+	m["nonce_str"] = util.RandomStr()
+	m["enc_bank_no"] = encBankNo
+	m["enc_true_name"] = encTrueName
+	m["bank_code"] = t.BankCode
+	m["amount"] = strconv.FormatInt(t.Amount, 10)
+	m["desc"] = t.Desc
+	sign, err := WechatGenSign(wc.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	var re common.WeChatBankTransferResult
+	err = wc.retry(ctx, func() error {
+		re = common.WeChatBankTransferResult{}
+		return wc.post(ctx, wechatGateWay+"/mmpaysptrans/pay_bank", m, &re)
+	})
+	if err != nil {
+		wc.resetPublicKey(err)
+		return &re, transferError(err)
+	}
+	return &re, nil
+}
+
+// QueryBankTransfer 查询企业付款到银行卡(mmpaysptrans/query_bank)
+func (wc *WechatTransferClient) QueryBankTransfer(ctx context.Context, tradeNum string) (*common.WeChatBankTransferInfo, error) {
+	if wc.CertClient == nil {
+		return nil, errors.New("wechat transfer: CertClient is required")
+	}
+	var m = make(map[string]string)
+	m["mch_id"] = wc.MchID
+	m["partner_trade_no"] = tradeNum
+	m["nonce_str"] = util.RandomStr()
+	sign, err := WechatGenSign(wc.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	var re common.WeChatBankTransferInfo
+	err = wc.retry(ctx, func() error {
+		re = common.WeChatBankTransferInfo{}
+		return wc.post(ctx, wechatGateWay+"/mmpaysptrans/query_bank", m, &re)
+	})
+	if err != nil {
+		return &re, err
+	}
+	return &re, nil
+}
+
+// PublicKey 返回付款到银行卡加密用的RSA公钥: 优先用BankPublicKey, 否则从risk/getpublickey获取后缓存,
+// 缓存超过bankKeyTTL或微信解密失败后重新获取
+func (wc *WechatTransferClient) PublicKey(ctx context.Context) (*rsa.PublicKey, error) {
+	if wc.BankPublicKey != nil {
+		return wc.BankPublicKey, nil
+	}
+	wc.mu.Lock()
+	key, fetchedAt := wc.bankKey, wc.bankKeyAt
+	wc.mu.Unlock()
+	if key != nil && time.Since(fetchedAt) < bankKeyTTL {
+		return key, nil
+	}
+
+	// 获取时不持有锁, 避免并发付款排队等待重试
+	key, err := wc.fetchPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wc.mu.Lock()
+	wc.bankKey, wc.bankKeyAt = key, time.Now()
+	wc.mu.Unlock()
+	return key, nil
+}
+
+// fetchPublicKey 调用risk/getpublickey获取RSA公钥
+func (wc *WechatTransferClient) fetchPublicKey(ctx context.Context) (*rsa.PublicKey, error) {
+	if wc.CertClient == nil {
+		return nil, errors.New("wechat transfer: CertClient is required")
+	}
+	var m = make(map[string]string)
+	m["mch_id"] = wc.MchID
+	m["nonce_str"] = util.RandomStr()
+	m["sign_type"] = "MD5"
+	sign, err := WechatGenSign(wc.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	var re struct {
+		common.WechatBaseResult
+		ResultCode string `xml:"result_code"`
+		ErrCode    string `xml:"err_code"`
+		ErrCodeDes string `xml:"err_code_des"`
+		PubKey     string `xml:"pub_key"`
+	}
+	err = wc.retry(ctx, func() error {
+		return wc.post(ctx, wechatRiskGateWay+"/risk/getpublickey", m, &re)
+	})
+	if err != nil {
+		return nil, errors.New("getpublickey: " + err.Error())
+	}
+	key, err := parseRSAPublicKey(re.PubKey)
+	if err != nil {
+		return nil, errors.New("getpublickey: " + err.Error())
+	}
+	return key, nil
+}
+
+// resetPublicKey 微信无法解密时公钥可能已更换, 清除缓存, 下次付款重新获取
+func (wc *WechatTransferClient) resetPublicKey(err error) {
+	var werr *WechatError
+	if !errors.As(err, &werr) {
+		return
+	}
+	if werr.ErrCode == wechatEncryptError {
+		wc.mu.Lock()
+		wc.bankKey = nil
+		wc.mu.Unlock()
+	}
+}
+
+// wechatEncrypt RSA/ECB/OAEPWITHSHA-1ANDMGF1PADDING加密后base64
+func wechatEncrypt(key *rsa.PublicKey, s string) (string, error) {
+	b, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, []byte(s), nil)
+	if err != nil {
+		return "", errors.New("rsa.EncryptOAEP: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// parseRSAPublicKey 解析PEM公钥, 微信返回PKCS#1格式, 也接受PKIX格式
+func parseRSAPublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("x509.ParsePKIXPublicKey: " + err.Error())
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not a rsa public key")
+	}
+	return key, nil
+}
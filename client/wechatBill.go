@@ -0,0 +1,324 @@
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+// 微信对账单类型
+const (
+	WechatBillAll     = "ALL"     // 当日所有订单
+	WechatBillSuccess = "SUCCESS" // 当日成功支付的订单
+	WechatBillRefund  = "REFUND"  // 当日退款订单
+)
+
+// wechatBillError 下载对账单失败时返回的xml
+type wechatBillError struct {
+	common.WechatBaseResult
+	ErrorCode string `xml:"error_code"`
+}
+
+// DownloadBill 下载对账单, date取其年月日, 读取完需Close
+func (wc *WechatAppClient) DownloadBill(ctx context.Context, date time.Time, billType string) (*BillIterator, error) {
+	key, err := wc.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": wc.AppID, "mch_id": wc.MchID}
+	return wechatDownloadBill(ctx, wechatEndpoint(wechatGateWay+"/pay/downloadbill", wc.CrossBorder, wc.InsideSandbox), key, m, date, billType)
+}
+
+// DownloadBill 下载对账单, date取其年月日, 读取完需Close
+func (wc *WechatWebClient) DownloadBill(ctx context.Context, date time.Time, billType string) (*BillIterator, error) {
+	key, err := wc.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": wc.AppID, "mch_id": wc.MchID}
+	if wc.SubMch {
+		m["sub_mch_id"] = wc.SubMchID
+	}
+	return wechatDownloadBill(ctx, wechatEndpoint(wechatGateWay+"/pay/downloadbill", wc.CrossBorder, wc.InsideSandbox), key, m, date, billType)
+}
+
+// DownloadBill 下载对账单, date取其年月日, 读取完需Close
+func (ac *WechatMiniProgramClient) DownloadBill(ctx context.Context, date time.Time, billType string) (*BillIterator, error) {
+	key, err := ac.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": ac.AppID, "mch_id": ac.MchID}
+	return wechatDownloadBill(ctx, wechatEndpoint(wechatGateWay+"/pay/downloadbill", ac.CrossBorder, ac.InsideSandbox), key, m, date, billType)
+}
+
+// wechatDownloadBill 请求pay/downloadbill, 账单以gzip压缩传输, 边下载边解析
+func wechatDownloadBill(ctx context.Context, url, key string, m map[string]string, date time.Time, billType string) (*BillIterator, error) {
+	switch billType {
+	case WechatBillAll, WechatBillSuccess, WechatBillRefund:
+	default:
+		return nil, errors.New("unsupported wechat bill type: " + billType)
+	}
+	m["nonce_str"] = util.RandomStr()
+	m["bill_date"] = date.Format("20060102")
+	m["bill_type"] = billType
+	m["tar_type"] = "GZIP"
+	sign, err := WechatGenSign(key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	r, cs, err := wechatDownload(ctx, HTTPSC, url, m)
+	if err != nil {
+		return nil, errors.New("downloadbill: " + err.Error())
+	}
+	it := ParseWechatBill(r)
+	it.closers = cs
+	return it, nil
+}
+
+// wechatDownload 提交已签名的请求下载账单, 返回解压后的账单内容和需要关闭的连接.
+// 账单不存在等失败时微信返回xml
+func wechatDownload(ctx context.Context, hc *HTTPSClient, url string, m map[string]string) (io.Reader, closers, error) {
+	resp, err := doWechatXML(ctx, hc, url, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	br := bufio.NewReader(resp.Body)
+	head, err := br.Peek(2)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, errors.New("empty response")
+	}
+	switch {
+	case head[0] == 0x1f && head[1] == 0x8b:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			resp.Body.Close()
+			return nil, nil, errors.New("gzip.NewReader: " + err.Error())
+		}
+		return gz, closers{gz, resp.Body}, nil
+	case head[0] == '<':
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		var re wechatBillError
+		err = xml.Unmarshal(body, &re)
+		if err != nil {
+			return nil, nil, errors.New("xml.Unmarshal: " + err.Error())
+		}
+		return nil, nil, errors.New(re.ErrorCode + " " + re.ReturnMsg)
+	default:
+		return br, closers{resp.Body}, nil
+	}
+}
+
+// ParseWechatBill 读取微信对账单文本(未压缩), 可用于解析已下载的账单文件
+func ParseWechatBill(r io.Reader) *BillIterator {
+	return &BillIterator{p: &wechatBillParser{c: newWechatCSV(r)}}
+}
+
+type wechatBillParser struct {
+	c   *wechatCSV
+	sum *common.BillSummary
+}
+
+func (p *wechatBillParser) next() (*common.BillRecord, error) {
+	f, isSummary, err := p.c.next()
+	if err != nil {
+		return nil, err
+	}
+	if isSummary {
+		p.sum, err = wechatBillSummary(f)
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	r, err := wechatBillRecord(f)
+	if err != nil {
+		return nil, p.c.errorf(err)
+	}
+	return r, nil
+}
+
+func (p *wechatBillParser) summary() *common.BillSummary {
+	return p.sum
+}
+
+func wechatBillRecord(f billFields) (*common.BillRecord, error) {
+	currency := f.str("货币种类")
+	if currency == "" {
+		currency = constant.CNY
+	}
+	d := currencyDecimals(currency)
+	r := &common.BillRecord{
+		TradeTime:         f.time("交易时间"),
+		AppID:             f.str("公众账号ID"),
+		MchID:             f.str("商户号"),
+		SubMchID:          f.str("特约商户号", "子商户号"),
+		DeviceInfo:        f.str("设备号"),
+		TransactionID:     f.str("微信订单号"),
+		TradeNum:          f.str("商户订单号"),
+		Payer:             f.str("用户标识"),
+		TradeType:         f.str("交易类型"),
+		TradeState:        f.str("交易状态"),
+		BankType:          f.str("付款银行"),
+		Currency:          currency,
+		SettlementFee:     f.amount(d, "应结订单金额", "总金额"),
+		CouponFee:         f.amount(d, "代金券金额", "代金券或立减优惠金额"),
+		TotalFee:          f.amount(d, "订单金额"),
+		RefundApplyTime:   f.time("退款申请时间"),
+		RefundSuccessTime: f.time("退款成功时间"),
+		RefundID:          f.str("微信退款单号"),
+		OutRefundNo:       f.str("商户退款单号"),
+		RefundFee:         f.amount(d, "退款金额"),
+		CouponRefundFee:   f.amount(d, "充值券退款金额", "代金券或立减优惠退款金额"),
+		ApplyRefundFee:    f.amount(d, "申请退款金额"),
+		RefundType:        f.str("退款类型"),
+		RefundStatus:      f.str("退款状态"),
+		Subject:           f.str("商品名称"),
+		Attach:            f.str("商户数据包"),
+		ServiceFee:        f.fee(d, "手续费"),
+		Rate:              f.str("费率"),
+		RateRemark:        f.str("费率备注"),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	// 非退款记录的退款单号为0
+	if r.RefundID == "0" {
+		r.RefundID = ""
+	}
+	if r.OutRefundNo == "0" {
+		r.OutRefundNo = ""
+	}
+	return r, nil
+}
+
+func wechatBillSummary(f billFields) (*common.BillSummary, error) {
+	d := currencyDecimals(constant.CNY)
+	s := &common.BillSummary{
+		TradeCount:      f.count("总交易单数"),
+		SettlementFee:   f.amount(d, "应结订单总金额", "总交易额"),
+		RefundFee:       f.amount(d, "退款总金额", "总退款金额"),
+		CouponRefundFee: f.amount(d, "充值券退款总金额", "总代金券或立减优惠退款金额"),
+		ServiceFee:      f.fee(d, "手续费总金额"),
+		TotalFee:        f.amount(d, "订单总金额"),
+		ApplyRefundFee:  f.amount(d, "申请退款总金额"),
+	}
+	if f.err != nil {
+		return nil, errors.New("wechat bill summary: " + f.err.Error())
+	}
+	return s, nil
+}
+
+// wechatCSV 微信账单文本: 表头, 每个字段以`开头的数据行, 汇总表头和汇总行
+type wechatCSV struct {
+	r      *bufio.Reader
+	header []string
+	line   int
+	done   bool
+}
+
+func newWechatCSV(r io.Reader) *wechatCSV {
+	return &wechatCSV{r: bufio.NewReader(r)}
+}
+
+// next 返回下一数据行, 读到汇总行时isSummary为true, 之后返回io.EOF
+func (c *wechatCSV) next() (f billFields, isSummary bool, err error) {
+	if c.done {
+		return f, false, io.EOF
+	}
+	for {
+		line, err := c.readLine()
+		if err == io.EOF {
+			if c.header == nil {
+				return f, false, errors.New("wechat bill: empty bill")
+			}
+			return f, false, io.EOF
+		}
+		if err != nil {
+			return f, false, err
+		}
+		if line == "" {
+			continue
+		}
+		if c.header == nil {
+			c.header = strings.Split(line, ",")
+			continue
+		}
+		if strings.HasPrefix(line, "`") {
+			f, err = c.fields(c.header, line)
+			return f, false, err
+		}
+		// 不以`开头的是汇总表头, 下一行为汇总
+		c.done = true
+		header := strings.Split(line, ",")
+		for {
+			line, err = c.readLine()
+			if err == io.EOF {
+				return f, false, errors.New("wechat bill: missing summary")
+			}
+			if err != nil {
+				return f, false, err
+			}
+			if line != "" {
+				f, err = c.fields(header, line)
+				return f, true, err
+			}
+		}
+	}
+}
+
+func (c *wechatCSV) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	c.line++
+	if c.line == 1 {
+		line = strings.TrimPrefix(line, "\ufeff")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *wechatCSV) fields(header []string, line string) (billFields, error) {
+	values := strings.Split(strings.TrimPrefix(line, "`"), ",`")
+	if len(values) != len(header) {
+		return billFields{}, c.errorf(errors.New("expected " + strconv.Itoa(len(header)) + " fields, got " + strconv.Itoa(len(values))))
+	}
+	m := make(map[string]string, len(header))
+	for i, h := range header {
+		m[strings.TrimSpace(h)] = strings.TrimSpace(values[i])
+	}
+	return billFields{m: m}, nil
+}
+
+// errorf 错误信息带上行号
+func (c *wechatCSV) errorf(err error) error {
+	return errors.New("wechat bill line " + strconv.Itoa(c.line) + ": " + err.Error())
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseWechatBill(t *testing.T) {
+	bill := "\ufeff交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\r\n" +
+		"`2014-11-10 16:33:45,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1001690740201411100005734289,`1415640626,`085e9858e3ba5186aafcbaed1,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付测试,`订单额外描述,`0.00006,`0.60%,`0.01,`0.00,`\r\n" +
+		"`2014-11-10 16:46:14,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1002780740201411100005729794,`1415635270,`085e9858e90ca40c0b5aee463,`MICROPAY,`REFUND,`OTHERS,`CNY,`1.23,`0.0,`2008450740201411110000174436,`1415701182,`1.23,`0.0,`ORIGINAL,`SUCCESS,`被扫支付, 测试,`,`-0.00738,`0.60%,`1.23,`1.23,`\r\n" +
+		"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+		"`2,`1.24,`1.23,`0.0,`-0.00732,`1.24,`1.23\r\n"
+
+	it := ParseWechatBill(strings.NewReader(bill))
+	var n int
+	for it.Next() {
+		n++
+		r := it.Record()
+		switch r.TradeNum {
+		case "1415640626":
+			if r.TotalFee != 1 || r.ServiceFee != 0 || r.TradeTime.Unix() != 1415608425 || r.Currency != "CNY" {
+				t.Fatalf("unexpected record %+v", r)
+			}
+		case "1415635270":
+			if r.TradeState != "REFUND" || r.RefundFee != 123 || r.ServiceFee != -1 || r.Subject != "被扫支付, 测试" || r.OutRefundNo != "1415701182" {
+				t.Fatalf("unexpected record %+v", r)
+			}
+		default:
+			t.Fatalf("unexpected record %+v", r)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	s := it.Summary()
+	if n != 2 || s == nil || s.TradeCount != 2 || s.TotalFee != 124 || s.RefundFee != 123 || s.ServiceFee != -1 {
+		t.Fatalf("unexpected summary %+v (%d records)", s, n)
+	}
+
+	it = ParseWechatBill(strings.NewReader("交易时间,商户订单号,订单金额\r\n`2014-11-10 16:33:45,`1415640626,`0.015\r\n"))
+	if it.Next() || it.Err() == nil {
+		t.Fatal("parsed an amount with more than 2 decimals")
+	}
+}
+
+func TestWechatDownloadStatus(t *testing.T) {
+	hc := &HTTPSClient{Client: http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		// 网关错误页不是账单
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader("Bad Gateway"))}, nil
+	})}}
+	_, _, err := wechatDownload(context.Background(), hc, wechatGateWay+"/pay/downloadbill", map[string]string{"mch_id": "10000100"})
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("wechatDownload returned %v, want http status error", err)
+	}
+}
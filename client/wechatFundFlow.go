@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+// 微信资金账户类型
+const (
+	WechatAccountBasic     = "Basic"     // 基本账户
+	WechatAccountOperation = "Operation" // 运营账户
+	WechatAccountFees      = "Fees"      // 手续费账户
+)
+
+// FundFlowIterator 逐条读取资金账单, 用法同BillIterator
+type FundFlowIterator struct {
+	c       *wechatCSV
+	closers closers
+	record  *common.FundFlowRecord
+	summary *common.FundFlowSummary
+	err     error
+	done    bool
+}
+
+// ParseWechatFundFlow 读取微信资金账单文本(未压缩), 可用于解析已下载的账单文件
+func ParseWechatFundFlow(r io.Reader) *FundFlowIterator {
+	return &FundFlowIterator{c: newWechatCSV(r)}
+}
+
+// Next 读取下一笔资金变动, 读完或出错时返回false
+func (it *FundFlowIterator) Next() bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	f, isSummary, err := it.c.next()
+	if err == io.EOF {
+		it.done = true
+		return false
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if isSummary {
+		it.summary, it.err = wechatFundFlowSummary(f)
+		it.done = true
+		return false
+	}
+	it.record, err = wechatFundFlowRecord(f)
+	if err != nil {
+		it.err = it.c.errorf(err)
+		return false
+	}
+	return true
+}
+
+// Record 当前记录
+func (it *FundFlowIterator) Record() *common.FundFlowRecord {
+	return it.record
+}
+
+// Summary 账单汇总, Next返回false后可用
+func (it *FundFlowIterator) Summary() *common.FundFlowSummary {
+	return it.summary
+}
+
+// Err 读取过程中的错误
+func (it *FundFlowIterator) Err() error {
+	return it.err
+}
+
+// Close 关闭下载连接
+func (it *FundFlowIterator) Close() error {
+	err := it.closers.close()
+	it.closers = nil
+	return err
+}
+
+// DownloadFundFlow 下载资金账单, 需配置CertClient, date取其年月日, 读取完需Close
+func (wc *WechatAppClient) DownloadFundFlow(ctx context.Context, date time.Time, accountType string) (*FundFlowIterator, error) {
+	key, err := wc.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": wc.AppID, "mch_id": wc.MchID}
+	return wechatDownloadFundFlow(ctx, wc.CertClient, wechatEndpoint(wechatGateWay+"/pay/downloadfundflow", wc.CrossBorder, wc.InsideSandbox), key, m, date, accountType)
+}
+
+// DownloadFundFlow 下载资金账单, 需配置CertClient, date取其年月日, 读取完需Close
+func (wc *WechatWebClient) DownloadFundFlow(ctx context.Context, date time.Time, accountType string) (*FundFlowIterator, error) {
+	key, err := wc.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": wc.AppID, "mch_id": wc.MchID}
+	return wechatDownloadFundFlow(ctx, wc.CertClient, wechatEndpoint(wechatGateWay+"/pay/downloadfundflow", wc.CrossBorder, wc.InsideSandbox), key, m, date, accountType)
+}
+
+// DownloadFundFlow 下载资金账单, 需配置CertClient, date取其年月日, 读取完需Close
+func (ac *WechatMiniProgramClient) DownloadFundFlow(ctx context.Context, date time.Time, accountType string) (*FundFlowIterator, error) {
+	key, err := ac.signKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{"appid": ac.AppID, "mch_id": ac.MchID}
+	return wechatDownloadFundFlow(ctx, ac.CertClient, wechatEndpoint(wechatGateWay+"/pay/downloadfundflow", ac.CrossBorder, ac.InsideSandbox), key, m, date, accountType)
+}
+
+// wechatDownloadFundFlow 请求pay/downloadfundflow, 只支持HMAC-SHA256签名, 需要商户证书
+func wechatDownloadFundFlow(ctx context.Context, hc *HTTPSClient, url, key string, m map[string]string, date time.Time, accountType string) (*FundFlowIterator, error) {
+	if hc == nil {
+		return nil, errors.New("downloadfundflow: CertClient is required")
+	}
+	switch accountType {
+	case WechatAccountBasic, WechatAccountOperation, WechatAccountFees:
+	default:
+		return nil, errors.New("unsupported wechat account type: " + accountType)
+	}
+	m["nonce_str"] = util.RandomStr()
+	m["sign_type"] = "HMAC-SHA256"
+	m["bill_date"] = date.Format("20060102")
+	m["account_type"] = accountType
+	m["tar_type"] = "GZIP"
+	sign, err := WechatGenHMACSign(key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	r, cs, err := wechatDownload(ctx, hc, url, m)
+	if err != nil {
+		return nil, errors.New("downloadfundflow: " + err.Error())
+	}
+	it := ParseWechatFundFlow(r)
+	it.closers = cs
+	return it, nil
+}
+
+func wechatFundFlowRecord(f billFields) (*common.FundFlowRecord, error) {
+	d := currencyDecimals(constant.CNY)
+	r := &common.FundFlowRecord{
+		Time:          f.time("记账时间"),
+		TransactionID: f.str("微信支付业务单号"),
+		FlowID:        f.str("资金流水单号"),
+		BizName:       f.str("业务名称"),
+		BizType:       f.str("业务类型"),
+		Direction:     f.str("收支类型"),
+		Amount:        f.amount(d, "收支金额（元）", "收支金额(元)", "收支金额"),
+		Balance:       f.amount(d, "账户结余（元）", "账户结余(元)", "账户结余"),
+		Applicant:     f.str("资金变更提交申请人"),
+		Remark:        f.str("备注"),
+		VoucherNo:     f.str("业务凭证号"),
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return r, nil
+}
+
+func wechatFundFlowSummary(f billFields) (*common.FundFlowSummary, error) {
+	d := currencyDecimals(constant.CNY)
+	s := &common.FundFlowSummary{
+		Count:         f.count("资金流水总笔数"),
+		IncomeCount:   f.count("收入笔数"),
+		IncomeAmount:  f.amount(d, "收入金额"),
+		ExpenseCount:  f.count("支出笔数"),
+		ExpenseAmount: f.amount(d, "支出金额"),
+	}
+	if f.err != nil {
+		return nil, errors.New("wechat fund flow summary: " + f.err.Error())
+	}
+	return s, nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWechatGenHMACSign(t *testing.T) {
+	m := map[string]string{"appid": "wxd930ea5d5a258f4f", "mch_id": "10000100", "device_info": "1000", "body": "test", "nonce_str": "ibuaiVcKdpRxkhJA"}
+	sign, err := WechatGenHMACSign("192006250b4c09247ec02edce69f6a2d", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sign != "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6" {
+		t.Fatalf("sign = %s", sign)
+	}
+}
+
+func TestParseWechatFundFlow(t *testing.T) {
+	bill := "记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\r\n" +
+		"`2018-02-01 04:21:23,`50000305742018020103387128253,`1900009231201802015884652186,`退款,`退款,`支出,`0.02,`0.17,`system,`缺货,`REF4200000068201801293084726067\r\n" +
+		"`2018-02-01 04:21:24,`4200000068201801293084726067,`1900009231201802015884652187,`交易,`交易,`收入,`1.00,`1.17,`system,`,`1217752501201407033233368018\r\n" +
+		"资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\r\n" +
+		"`2,`1,`1.00,`1,`0.02\r\n"
+	it := ParseWechatFundFlow(strings.NewReader(bill))
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	r := it.Record()
+	if r.Direction != "支出" || r.Amount != 2 || r.Balance != 17 || r.BizName != "退款" || r.Remark != "缺货" || r.Time.Unix() != 1517430083 {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if !it.Next() || it.Record().Amount != 100 {
+		t.Fatalf("unexpected record %+v, %v", it.Record(), it.Err())
+	}
+	if it.Next() || it.Err() != nil {
+		t.Fatalf("unexpected trailing record %+v, %v", it.Record(), it.Err())
+	}
+	if s := it.Summary(); s == nil || s.Count != 2 || s.IncomeAmount != 100 || s.ExpenseAmount != 2 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestDownloadFundFlowRequiresCert(t *testing.T) {
+	wc := &WechatAppClient{AppID: "wx", MchID: "100", Key: "key"}
+	if _, err := wc.DownloadFundFlow(context.Background(), time.Now(), WechatAccountBasic); err == nil || !strings.Contains(err.Error(), "CertClient") {
+		t.Fatalf("err = %v", err)
+	}
+}
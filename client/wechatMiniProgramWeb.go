@@ -1,9 +1,9 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/sulrex/gopay/common"
 	"github.com/sulrex/gopay/util"
@@ -23,12 +23,15 @@ func DefaultWechatMiniProgramClient() *WechatMiniProgramClient {
 
 // WechatMiniProgramClient 微信小程序
 type WechatMiniProgramClient struct {
-	AppID       string // 公众账号ID
-	MchID       string // 商户号ID
-	CallbackURL string // 回调地址
-	Key         string // 密钥
-	PayURL      string // 支付地址
-	QueryURL    string // 查询地址
+	AppID         string       // 公众账号ID
+	MchID         string       // 商户号ID
+	CallbackURL   string       // 回调地址
+	Key           string       // 密钥
+	PayURL        string       // 支付地址
+	QueryURL      string       // 查询地址
+	InsideSandbox bool         // 沙箱阶段
+	CrossBorder   bool         // 境外商户, 使用香港接入点
+	CertClient    *HTTPSClient // 商户证书客户端(NewTLSClient), 资金账单等接口使用
 }
 
 // Pay 支付
@@ -39,32 +42,57 @@ func (ac *WechatMiniProgramClient) Pay(charge *common.Charge) (map[string]string
 	m["nonce_str"] = util.RandomStr()
 	m["body"] = TruncatedText(charge.Describe, 32)
 	m["out_trade_no"] = charge.TradeNum
-	m["total_fee"] = WechatMoneyFeeToString(charge.MoneyFee)
-	m["spbill_create_ip"] = util.LocalIP()
+	err := wechatAmountParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	clientIP, err := chargeClientIP(charge)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	m["spbill_create_ip"] = clientIP
+	err = wechatExpireParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	if len(charge.Metadata) > 0 {
+		m["attach"], err = wechatAttach(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	err = wechatGoodsParams(charge, m)
+	if err != nil {
+		return map[string]string{}, err
+	}
 	m["notify_url"] = charge.CallbackURL
 	m["trade_type"] = "JSAPI"
 	m["openid"] = charge.OpenID
 	m["sign_type"] = "MD5"
 
-	sign, err := WechatGenSign(ac.Key, m)
+	key, err := ac.SignKey()
+	if err != nil {
+		return map[string]string{}, err
+	}
+	sign, err := WechatGenSign(key, m)
 	if err != nil {
 		return map[string]string{}, err
 	}
 	m["sign"] = sign
 
 	// 转出xml结构
-	xmlRe, err := PostWechat(ac.PayURL, m)
+	xmlRe, err := PostWechat(wechatEndpoint(ac.PayURL, ac.CrossBorder, ac.InsideSandbox), m)
 	if err != nil {
 		return map[string]string{}, err
 	}
 
 	var c = make(map[string]string)
 	c["appId"] = ac.AppID
-	c["timeStamp"] = fmt.Sprintf("%d", time.Now().Unix())
+	c["timeStamp"] = fmt.Sprintf("%d", util.Now().Unix())
 	c["nonceStr"] = util.RandomStr()
 	c["package"] = fmt.Sprintf("prepay_id=%s", xmlRe.PrepayID)
 	c["signType"] = "MD5"
-	sign2, err := WechatGenSign(ac.Key, c)
+	sign2, err := WechatGenSign(key, c)
 	if err != nil {
 		return map[string]string{}, errors.New("WechatWeb: " + err.Error())
 	}
@@ -74,18 +102,49 @@ func (ac *WechatMiniProgramClient) Pay(charge *common.Charge) (map[string]string
 
 // QueryOrder 查询订单
 func (ac *WechatMiniProgramClient) QueryOrder(tradeNum string) (common.WeChatQueryResult, error) {
+	return ac.queryOrder(context.Background(), tradeNum)
+}
+
+// Query 查询订单, 返回统一的查询结果
+func (ac *WechatMiniProgramClient) Query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	re, err := ac.queryOrder(ctx, tradeNum)
+	if err != nil {
+		return nil, err
+	}
+	return wechatQueryResult(re)
+}
+
+func (ac *WechatMiniProgramClient) queryOrder(ctx context.Context, tradeNum string) (common.WeChatQueryResult, error) {
 	var m = make(map[string]string)
 	m["appid"] = ac.AppID
 	m["mch_id"] = ac.MchID
 	m["out_trade_no"] = tradeNum
 	m["nonce_str"] = util.RandomStr()
+	m["version"] = "1.0" // 返回单品优惠信息
 
-	sign, err := WechatGenSign(ac.Key, m)
+	key, err := ac.signKey(ctx)
+	if err != nil {
+		return common.WeChatQueryResult{}, err
+	}
+	sign, err := WechatGenSign(key, m)
 	if err != nil {
 		return common.WeChatQueryResult{}, err
 	}
 
 	m["sign"] = sign
 
-	return PostWechat("https://api.mch.weixin.qq.com/pay/orderquery", m)
+	return postWechat(ctx, wechatEndpoint(wechatGateWay+"/pay/orderquery", ac.CrossBorder, ac.InsideSandbox), m)
+}
+
+// SignKey 签名密钥, 沙箱阶段为沙箱密钥
+func (ac *WechatMiniProgramClient) SignKey() (string, error) {
+	return ac.signKey(context.Background())
+}
+
+// signKey 签名密钥, 沙箱阶段获取沙箱密钥时使用ctx
+func (ac *WechatMiniProgramClient) signKey(ctx context.Context) (string, error) {
+	if ac.InsideSandbox {
+		return WechatSandboxSignKey(ctx, ac.MchID, ac.Key, ac.CrossBorder)
+	}
+	return ac.Key, nil
 }
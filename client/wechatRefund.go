@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// WechatParseRefundNotify 解析退款结果通知并解密req_info
+func WechatParseRefundNotify(key string, body []byte) (*common.WeChatRefundResult, error) {
+	var re common.WeChatRefundResult
+	err := xml.Unmarshal(body, &re.WeChatRefundNotify)
+	if err != nil {
+		return nil, errors.New("xml.Unmarshal: " + err.Error())
+	}
+	if re.ReturnCode != "SUCCESS" {
+		return &re, errors.New("re.ReturnMsg: " + re.ReturnMsg)
+	}
+
+	info, err := WechatDecryptReqInfo(key, re.ReqInfo)
+	if err != nil {
+		return &re, err
+	}
+	err = xml.Unmarshal(info, &re.WeChatRefundInfo)
+	if err != nil {
+		return &re, errors.New("xml.Unmarshal req_info: " + err.Error())
+	}
+	return &re, nil
+}
+
+// WechatDecryptReqInfo 解密退款通知的req_info(AES-256-ECB, 密钥为商户key的md5小写)
+func WechatDecryptReqInfo(key, reqInfo string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		return nil, errors.New("WechatDecryptReqInfo base64: " + err.Error())
+	}
+	block, err := aes.NewCipher([]byte(fmt.Sprintf("%x", md5.Sum([]byte(key)))))
+	if err != nil {
+		return nil, err
+	}
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, errors.New("WechatDecryptReqInfo: invalid ciphertext length")
+	}
+	plain := make([]byte, len(data))
+	for i := 0; i < len(data); i += size {
+		block.Decrypt(plain[i:i+size], data[i:i+size])
+	}
+	return pkcs7Unpad(plain, size)
+}
+
+// pkcs7Unpad 去除PKCS#7填充
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	n := len(data)
+	if n == 0 || n%blockSize != 0 {
+		return nil, errors.New("pkcs7Unpad: invalid data length")
+	}
+	pad := int(data[n-1])
+	if pad == 0 || pad > blockSize || !bytes.Equal(data[n-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("pkcs7Unpad: invalid padding")
+	}
+	return data[:n-pad], nil
+}
+
+// pkcs7Pad PKCS#7填充
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	pad := blockSize - len(data)%blockSize
+	return append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+func TestWechatParseRefundNotify(t *testing.T) {
+	key := "192006250b4c09247ec02edce69f6a2d"
+	info := `<root><out_refund_no><![CDATA[131811191610442717309]]></out_refund_no>` +
+		`<out_trade_no><![CDATA[71106718111915575302817]]></out_trade_no>` +
+		`<refund_fee><![CDATA[3960]]></refund_fee>` +
+		`<refund_status><![CDATA[SUCCESS]]></refund_status>` +
+		`<settlement_refund_fee><![CDATA[3960]]></settlement_refund_fee>` +
+		`<total_fee><![CDATA[3960]]></total_fee></root>`
+
+	block, _ := aes.NewCipher([]byte(fmt.Sprintf("%x", md5.Sum([]byte(key)))))
+	plain := pkcs7Pad([]byte(info), block.BlockSize())
+	data := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += block.BlockSize() {
+		block.Encrypt(data[i:i+block.BlockSize()], plain[i:i+block.BlockSize()])
+	}
+	body := fmt.Sprintf(`<xml><return_code>SUCCESS</return_code><appid><![CDATA[wx2421b1c4370ec43b]]></appid>`+
+		`<mch_id><![CDATA[10000100]]></mch_id><req_info><![CDATA[%s]]></req_info></xml>`, base64.StdEncoding.EncodeToString(data))
+
+	re, err := WechatParseRefundNotify(key, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.MchID != "10000100" || re.OutRefundNo != "131811191610442717309" || re.RefundStatus != "SUCCESS" || re.SettlementRefundFee != 3960 {
+		t.Fatalf("unexpected result %+v", re)
+	}
+
+	if _, err := WechatParseRefundNotify("wrongkey", []byte(body)); err == nil {
+		t.Fatal("WechatParseRefundNotify accepted wrong key")
+	}
+}
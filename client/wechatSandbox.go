@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+const (
+	wechatGateWay   = "https://api.mch.weixin.qq.com"
+	wechatHKGateWay = "https://apihk.mch.weixin.qq.com" // 境外商户香港接入点
+)
+
+// 沙箱密钥缓存, 按商户号和密钥
+var wechatSandboxKeys = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// wechatURL 获取接口地址, 沙箱阶段改写为沙箱地址
+func wechatURL(u string, insideSandbox bool) string {
+	if !insideSandbox || strings.Contains(u, "/sandboxnew/") {
+		return u
+	}
+	return strings.Replace(u, ".weixin.qq.com/", ".weixin.qq.com/sandboxnew/", 1)
+}
+
+// wechatEndpoint 获取接口地址, 境外商户改写为香港接入点, 沙箱阶段改写为沙箱地址
+func wechatEndpoint(u string, crossBorder, insideSandbox bool) string {
+	if crossBorder {
+		u = strings.Replace(u, wechatGateWay, wechatHKGateWay, 1)
+	}
+	return wechatURL(u, insideSandbox)
+}
+
+// WechatSandboxSignKey 获取沙箱签名密钥, 获取成功后缓存, 境外商户使用香港接入点
+func WechatSandboxSignKey(ctx context.Context, mchID, key string, crossBorder bool) (string, error) {
+	cacheKey := mchID + "|" + key
+	wechatSandboxKeys.Lock()
+	signKey, ok := wechatSandboxKeys.m[cacheKey]
+	wechatSandboxKeys.Unlock()
+	if ok {
+		return signKey, nil
+	}
+
+	var m = make(map[string]string)
+	m["mch_id"] = mchID
+	m["nonce_str"] = util.RandomStr()
+	sign, err := WechatGenSign(key, m)
+	if err != nil {
+		return "", err
+	}
+	m["sign"] = sign
+
+	re, err := postWechatXML(ctx, wechatEndpoint(wechatGateWay+"/pay/getsignkey", crossBorder, true), m)
+	if err != nil {
+		return "", err
+	}
+	var xmlRe struct {
+		common.WechatBaseResult
+		SandboxSignKey string `xml:"sandbox_signkey"`
+	}
+	err = xml.Unmarshal(re, &xmlRe)
+	if err != nil {
+		return "", errors.New("xml.Unmarshal: " + err.Error())
+	}
+	if xmlRe.ReturnCode != "SUCCESS" {
+		return "", errors.New("xmlRe.ReturnMsg: " + xmlRe.ReturnMsg)
+	}
+	if xmlRe.SandboxSignKey == "" {
+		return "", errors.New("WechatSandboxSignKey: empty sandbox_signkey")
+	}
+
+	// 并发获取时沙箱返回的密钥相同, 重复写入无影响
+	wechatSandboxKeys.Lock()
+	wechatSandboxKeys.m[cacheKey] = xmlRe.SandboxSignKey
+	wechatSandboxKeys.Unlock()
+	return xmlRe.SandboxSignKey, nil
+}
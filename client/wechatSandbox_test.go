@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sulrex/gopay/util"
+)
+
+func TestWechatSandboxSignKey(t *testing.T) {
+	var urls []string
+	transport := HTTPSC.Transport
+	defer func() { HTTPSC.Transport = transport }()
+	HTTPSC.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		urls = append(urls, r.URL.String())
+		body, _ := ioutil.ReadAll(r.Body)
+		m := util.XmlToMap(body)
+		key := "SANDBOXKEY"
+		re := `<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><trade_state>NOTPAY</trade_state></xml>`
+		if strings.HasSuffix(r.URL.Path, "/getsignkey") {
+			key = m["mch_id"] + "-key"
+			re = `<xml><return_code>SUCCESS</return_code><sandbox_signkey>SANDBOXKEY</sandbox_signkey></xml>`
+		}
+		if sign, _ := WechatGenSign(key, m); sign != m["sign"] {
+			re = `<xml><return_code>FAIL</return_code><return_msg>签名错误</return_msg></xml>`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(re))}, nil
+	})
+
+	wc := &WechatAppClient{AppID: "wx1", MchID: "1900000109", Key: "1900000109-key", InsideSandbox: true, CrossBorder: true}
+	for i := 0; i < 2; i++ {
+		if _, err := wc.queryOrder(context.Background(), "T1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"https://apihk.mch.weixin.qq.com/sandboxnew/pay/getsignkey",
+		"https://apihk.mch.weixin.qq.com/sandboxnew/pay/orderquery",
+		"https://apihk.mch.weixin.qq.com/sandboxnew/pay/orderquery",
+	}
+	if strings.Join(urls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got requests %v, want %v", urls, want)
+	}
+
+	// 更换密钥后重新获取沙箱密钥
+	urls = nil
+	wc = &WechatAppClient{AppID: "wx1", MchID: "1900000109", Key: "other", InsideSandbox: true}
+	if _, err := wc.SignKey(); err == nil {
+		t.Fatal("SignKey used the sandbox key cached for another key")
+	}
+	if len(urls) != 1 || urls[0] != "https://api.mch.weixin.qq.com/sandboxnew/pay/getsignkey" {
+		t.Fatalf("got requests %v", urls)
+	}
+}
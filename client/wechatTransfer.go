@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+var defaultWechatTransferClient *WechatTransferClient
+
+// InitWxTransferClient ..
+func InitWxTransferClient(c *WechatTransferClient) {
+	defaultWechatTransferClient = c
+}
+
+// DefaultWechatTransferClient 默认微信企业付款客户端
+func DefaultWechatTransferClient() *WechatTransferClient {
+	return defaultWechatTransferClient
+}
+
+// ErrTransferUnknown 付款结果未知(微信重试后仍未知, 支付宝通信失败或系统繁忙), 需用原单号查询或用原单号原参数再次付款
+var ErrTransferUnknown = errors.New("transfer: result unknown")
+
+// 默认重试次数和间隔
+const (
+	defaultTransferRetries       = 2
+	defaultTransferRetryInterval = time.Second
+)
+
+// WechatTransferClient 微信企业付款到零钱
+type WechatTransferClient struct {
+	AppID         string         // 商户账号appid
+	MchID         string         // 商户号
+	Key           string         // 密钥
+	CertClient    *HTTPSClient   // 商户证书客户端(NewTLSClient), 必须
+	Retries       int            // 通信失败或系统繁忙时用原参数重试的次数, 0时默认2次, 小于0不重试
+	RetryInterval time.Duration  // 重试间隔, 0时默认1秒, 逐次递增
+	BankPublicKey *rsa.PublicKey // 付款到银行卡加密用的RSA公钥, 为空时从risk/getpublickey获取并缓存
+
+	mu        sync.Mutex
+	bankKey   *rsa.PublicKey
+	bankKeyAt time.Time
+}
+
+// WechatError 微信接口返回的失败
+type WechatError struct {
+	ReturnCode string
+	ReturnMsg  string
+	ErrCode    string
+	ErrCodeDes string
+}
+
+func (e *WechatError) Error() string {
+	if e.ReturnCode != "SUCCESS" {
+		return "wechat: return_code=" + e.ReturnCode + ", return_msg=" + e.ReturnMsg
+	}
+	return "wechat: err_code=" + e.ErrCode + ", err_code_des=" + e.ErrCodeDes
+}
+
+// retryable 系统繁忙或频率限制, 结果未知, 可用原参数重试
+func (e *WechatError) retryable() bool {
+	return e.ErrCode == "SYSTEMERROR" || e.ErrCode == "FREQ_LIMIT"
+}
+
+// Transfer 企业付款到零钱(mmpaymkttransfers/promotion/transfers).
+// 通信失败或SYSTEMERROR时以相同的参数和签名重试, partner_trade_no保证不会重复付款;
+// 重试后仍未知时返回ErrTransferUnknown, 此时应调用QueryTransfer确认
+func (wc *WechatTransferClient) Transfer(ctx context.Context, t *common.Transfer) (*common.WeChatTransferResult, error) {
+	if wc.CertClient == nil {
+		return nil, errors.New("wechat transfer: CertClient is required")
+	}
+	if t.TradeNum == "" || t.OpenID == "" || t.Desc == "" || t.Amount <= 0 {
+		return nil, errors.New("wechat transfer: TradeNum, OpenID, Desc and a positive Amount are required")
+	}
+	var m = make(map[string]string)
+	m["mch_appid"] = wc.AppID
+	m["mchid"] = wc.MchID
+	m["nonce_str"] = util.RandomStr()
+	m["partner_trade_no"] = t.TradeNum
+	m["openid"] = t.OpenID
+	m["amount"] = strconv.FormatInt(t.Amount, 10)
+	m["desc"] = t.Desc
+	m["device_info"] = t.DeviceInfo
+	m["check_name"] = "NO_CHECK"
+	if t.UserName != "" {
+		m["check_name"] = "FORCE_CHECK"
+		m["re_user_name"] = t.UserName
+	}
+	clientIP, err := chargeClientIP(&common.Charge{ClientIP: t.ClientIP})
+	if err != nil {
+		return nil, err
+	}
+	m["spbill_create_ip"] = clientIP
+	sign, err := WechatGenSign(wc.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	var re common.WeChatTransferResult
+	err = wc.retry(ctx, func() error {
+		re = common.WeChatTransferResult{}
+		return wc.post(ctx, wechatGateWay+"/mmpaymkttransfers/promotion/transfers", m, &re)
+	})
+	if err != nil {
+		return &re, transferError(err)
+	}
+	return &re, nil
+}
+
+// QueryTransfer 查询企业付款(mmpaymkttransfers/gettransferinfo), 查询本身可安全重试, 失败时返回原始错误而不是ErrTransferUnknown
+func (wc *WechatTransferClient) QueryTransfer(ctx context.Context, tradeNum string) (*common.WeChatTransferInfo, error) {
+	if wc.CertClient == nil {
+		return nil, errors.New("wechat transfer: CertClient is required")
+	}
+	var m = make(map[string]string)
+	m["appid"] = wc.AppID
+	m["mch_id"] = wc.MchID
+	m["nonce_str"] = util.RandomStr()
+	m["partner_trade_no"] = tradeNum
+	sign, err := WechatGenSign(wc.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	var re common.WeChatTransferInfo
+	err = wc.retry(ctx, func() error {
+		re = common.WeChatTransferInfo{}
+		return wc.post(ctx, wechatGateWay+"/mmpaymkttransfers/gettransferinfo", m, &re)
+	})
+	if err != nil {
+		return &re, err
+	}
+	return &re, nil
+}
+
+// retry 重试通信失败和可重试的业务错误, 返回最后一次的错误
+func (wc *WechatTransferClient) retry(ctx context.Context, do func() error) error {
+	retries := wc.Retries
+	if retries == 0 {
+		retries = defaultTransferRetries
+	}
+	interval := wc.RetryInterval
+	if interval == 0 {
+		interval = defaultTransferRetryInterval
+	}
+	for i := 0; ; i++ {
+		err := do()
+		var werr *WechatError
+		if err == nil || (errors.As(err, &werr) && !werr.retryable()) || i >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %w", err, ctx.Err())
+		case <-time.After(interval * time.Duration(i+1)):
+		}
+	}
+}
+
+// transferError 付款请求重试后的错误: 明确的业务失败原样返回, 其他情况付款结果未知
+func transferError(err error) error {
+	var werr *WechatError
+	if errors.As(err, &werr) && !werr.retryable() {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrTransferUnknown, err)
+}
+
+// post 用商户证书提交请求, 解析到out, 失败时返回*WechatError
+func (wc *WechatTransferClient) post(ctx context.Context, url string, m map[string]string, out interface{}) error {
+	resp, err := doWechatXML(ctx, wc.CertClient, url, m)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var werr WechatError
+	var re struct {
+		ReturnCode string `xml:"return_code"`
+		ReturnMsg  string `xml:"return_msg"`
+		ResultCode string `xml:"result_code"`
+		ErrCode    string `xml:"err_code"`
+		ErrCodeDes string `xml:"err_code_des"`
+	}
+	err = xml.Unmarshal(body, &re)
+	if err != nil {
+		return errors.New("xml.Unmarshal: " + err.Error())
+	}
+	err = xml.Unmarshal(body, out)
+	if err != nil {
+		return errors.New("xml.Unmarshal: " + err.Error())
+	}
+	werr = WechatError{ReturnCode: re.ReturnCode, ReturnMsg: re.ReturnMsg, ErrCode: re.ErrCode, ErrCodeDes: re.ErrCodeDes}
+	if re.ReturnCode != "SUCCESS" || re.ResultCode != "SUCCESS" {
+		return &werr
+	}
+	return nil
+}
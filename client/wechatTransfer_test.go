@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+)
+
+func TestWechatTransferErrors(t *testing.T) {
+	var calls int
+	busy := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		body := "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>SYSTEMERROR</err_code></xml>"
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})
+	wc := &WechatTransferClient{AppID: "wx1", MchID: "100", Key: "key", Retries: 1, RetryInterval: 1,
+		CertClient: &HTTPSClient{Client: http.Client{Transport: busy}}}
+
+	_, err := wc.Transfer(context.Background(), &common.Transfer{TradeNum: "T1", OpenID: "o1", Amount: 1, Desc: "test", ClientIP: "127.0.0.1"})
+	if !errors.Is(err, ErrTransferUnknown) || calls != 2 {
+		t.Fatalf("Transfer returned %v after %d calls, want ErrTransferUnknown after 2", err, calls)
+	}
+
+	// 查询失败不能被误认为付款结果未知
+	_, err = wc.QueryTransfer(context.Background(), "T1")
+	var werr *WechatError
+	if errors.Is(err, ErrTransferUnknown) || !errors.As(err, &werr) || werr.ErrCode != "SYSTEMERROR" {
+		t.Fatalf("QueryTransfer returned %v, want SYSTEMERROR", err)
+	}
+}
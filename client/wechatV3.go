@@ -0,0 +1,397 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+const wechatV3AuthSchema = "WECHATPAY2-SHA256-RSA2048"
+
+var defaultWechatV3Clients = make(map[int64]*WechatV3Client)
+
+// InitWxV3Client 按支付方式设置v3客户端, 同一商户的多个支付方式可共用一个客户端.
+// 未配置PlatformCerts时加入默认平台证书管理器, 自动下载和更新平台证书
+func InitWxV3Client(payMethod int64, c *WechatV3Client) {
+	if c.PlatformCerts == nil {
+		defaultWechatV3CertManager.Add(c)
+	}
+	defaultWechatV3Clients[payMethod] = c
+}
+
+// DefaultWechatV3Client 获取支付方式对应的v3客户端
+func DefaultWechatV3Client(payMethod int64) *WechatV3Client {
+	return defaultWechatV3Clients[payMethod]
+}
+
+// WechatV3CertStore 微信支付平台证书来源
+type WechatV3CertStore interface {
+	Certificate(mchID, serialNo string) (*x509.Certificate, error)
+}
+
+// WechatV3Certs 固定的平台证书, 按证书序列号索引
+type WechatV3Certs map[string]*x509.Certificate
+
+// Certificate 按序列号获取平台证书
+func (cs WechatV3Certs) Certificate(mchID, serialNo string) (*x509.Certificate, error) {
+	cert, ok := cs[serialNo]
+	if !ok {
+		return nil, errors.New("WechatV3Certs: unknown serial " + serialNo)
+	}
+	return cert, nil
+}
+
+// WechatV3Error v3接口错误应答
+type WechatV3Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *WechatV3Error) Error() string {
+	return fmt.Sprintf("wechat v3: status=%d, code=%s, message=%s", e.StatusCode, e.Code, e.Message)
+}
+
+// WechatV3Client 微信支付v3客户端(JSAPI/APP/H5/Native/小程序)
+type WechatV3Client struct {
+	AppID         string            // 公众账号ID/应用ID/小程序ID
+	MchID         string            // 商户号ID
+	SerialNo      string            // 商户API证书序列号
+	PrivateKey    *rsa.PrivateKey   // 商户API私钥
+	APIv3Key      string            // APIv3密钥
+	CallbackURL   string            // 回调地址, 下单未指定时使用
+	PlatformCerts WechatV3CertStore // 平台证书, 验证应答及通知签名
+}
+
+// Pay 支付, 按charge.PayMethod选择下单接口
+func (c *WechatV3Client) Pay(charge *common.Charge) (map[string]string, error) {
+	notifyURL := charge.CallbackURL
+	if notifyURL == "" {
+		notifyURL = c.CallbackURL
+	}
+	currency, err := chargeCurrency(charge)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	body := map[string]interface{}{
+		"appid":        c.AppID,
+		"mchid":        c.MchID,
+		"description":  TruncatedText(charge.Describe, 127), // 最多127个字符
+		"out_trade_no": charge.TradeNum,
+		"notify_url":   notifyURL,
+		"amount": map[string]interface{}{
+			"total":    minorAmount(charge.MoneyFee, currency),
+			"currency": currency,
+		},
+	}
+	_, expireAt, err := chargeExpireAt(charge, wechatMinTimeout, 0)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	if !expireAt.IsZero() {
+		body["time_expire"] = expireAt.In(chinaZone).Format(time.RFC3339)
+	}
+	if len(charge.Metadata) > 0 {
+		body["attach"], err = wechatAttach(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	err = wechatV3GoodsParams(charge, body)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	var re struct {
+		PrepayID string `json:"prepay_id"`
+		H5URL    string `json:"h5_url"`
+		CodeURL  string `json:"code_url"`
+	}
+	switch charge.PayMethod {
+	case constant.WECHAT_V3_JSAPI, constant.WECHAT_V3_MINI_PROGRAM:
+		body["payer"] = map[string]string{"openid": charge.OpenID}
+		if err := c.Do("POST", "/v3/pay/transactions/jsapi", body, &re); err != nil {
+			return map[string]string{}, err
+		}
+		return c.jsapiParams(re.PrepayID)
+	case constant.WECHAT_V3_APP:
+		if err := c.Do("POST", "/v3/pay/transactions/app", body, &re); err != nil {
+			return map[string]string{}, err
+		}
+		return c.appParams(re.PrepayID)
+	case constant.WECHAT_V3_H5:
+		clientIP, err := chargeClientIP(charge)
+		if err != nil {
+			return map[string]string{}, err
+		}
+		body["scene_info"] = map[string]interface{}{
+			"payer_client_ip": clientIP,
+			"h5_info":         map[string]string{"type": "Wap"},
+		}
+		if err := c.Do("POST", "/v3/pay/transactions/h5", body, &re); err != nil {
+			return map[string]string{}, err
+		}
+		return map[string]string{"h5_url": re.H5URL}, nil
+	case constant.WECHAT_V3_NATIVE:
+		if err := c.Do("POST", "/v3/pay/transactions/native", body, &re); err != nil {
+			return map[string]string{}, err
+		}
+		return map[string]string{"code_url": re.CodeURL}, nil
+	}
+	return map[string]string{}, fmt.Errorf("WechatV3: unsupported payMethod %d", charge.PayMethod)
+}
+
+// jsapiParams 生成JSAPI/小程序调起支付参数
+func (c *WechatV3Client) jsapiParams(prepayID string) (map[string]string, error) {
+	var m = make(map[string]string)
+	m["appId"] = c.AppID
+	m["timeStamp"] = fmt.Sprintf("%d", util.Now().Unix())
+	m["nonceStr"] = util.RandomStr()
+	m["package"] = "prepay_id=" + prepayID
+	m["signType"] = "RSA"
+	sign, err := c.Sign(wechatV3Message(m["appId"], m["timeStamp"], m["nonceStr"], m["package"]))
+	if err != nil {
+		return map[string]string{}, errors.New("WechatV3.paySign: " + err.Error())
+	}
+	m["paySign"] = sign
+	return m, nil
+}
+
+// appParams 生成APP调起支付参数
+func (c *WechatV3Client) appParams(prepayID string) (map[string]string, error) {
+	var m = make(map[string]string)
+	m["appid"] = c.AppID
+	m["partnerid"] = c.MchID
+	m["prepayid"] = prepayID
+	m["package"] = "Sign=WXPay"
+	m["noncestr"] = util.RandomStr()
+	m["timestamp"] = fmt.Sprintf("%d", util.Now().Unix())
+	sign, err := c.Sign(wechatV3Message(m["appid"], m["timestamp"], m["noncestr"], m["prepayid"]))
+	if err != nil {
+		return map[string]string{}, errors.New("WechatV3.sign: " + err.Error())
+	}
+	m["sign"] = sign
+	return m, nil
+}
+
+// QueryOrder 按商户订单号查询订单
+func (c *WechatV3Client) QueryOrder(tradeNum string) (common.WeChatV3Transaction, error) {
+	return c.queryOrder(context.Background(), tradeNum)
+}
+
+// Query 查询订单, 返回统一的查询结果
+func (c *WechatV3Client) Query(ctx context.Context, tradeNum string) (*common.QueryResult, error) {
+	re, err := c.queryOrder(ctx, tradeNum)
+	if err != nil {
+		return nil, err
+	}
+	return wechatV3QueryResult(re)
+}
+
+func (c *WechatV3Client) queryOrder(ctx context.Context, tradeNum string) (common.WeChatV3Transaction, error) {
+	var re common.WeChatV3Transaction
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s?mchid=%s", url.PathEscape(tradeNum), url.QueryEscape(c.MchID))
+	err := c.DoContext(ctx, "GET", path, nil, &re)
+	return re, err
+}
+
+// CloseOrder 关闭订单
+func (c *WechatV3Client) CloseOrder(tradeNum string) error {
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s/close", url.PathEscape(tradeNum))
+	return c.Do("POST", path, map[string]string{"mchid": c.MchID}, nil)
+}
+
+// Do 发送签名请求并验证应答签名, body为nil时不带请求体, out为nil时忽略应答内容
+func (c *WechatV3Client) Do(method, path string, body interface{}, out interface{}) error {
+	return c.DoContext(context.Background(), method, path, body, out)
+}
+
+// DoContext 同Do, 请求受ctx控制
+func (c *WechatV3Client) DoContext(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	respBody, header, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	err = c.VerifyResponse(header, respBody)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return errors.New("json.Unmarshal: " + err.Error())
+	}
+	return nil
+}
+
+// request 发送签名请求, 非2xx应答转换为WechatV3Error
+func (c *WechatV3Client) request(ctx context.Context, method, path string, body interface{}) ([]byte, http.Header, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return nil, nil, errors.New("json.Marshal: " + err.Error())
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, wechatGateWay+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := c.Authorization(method, path, string(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gopay")
+
+	resp, err := HTTPSC.Do(req)
+	if err != nil {
+		return nil, nil, errors.New("HTTPSC.Do: " + err.Error())
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &WechatV3Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, e)
+		return nil, nil, e
+	}
+	return respBody, resp.Header, nil
+}
+
+// Authorization 生成请求的Authorization头
+func (c *WechatV3Client) Authorization(method, path, body string) (string, error) {
+	nonce := util.RandomStr()
+	timestamp := strconv.FormatInt(util.Now().Unix(), 10)
+	sign, err := c.Sign(wechatV3Message(method, path, timestamp, nonce, body))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		wechatV3AuthSchema, c.MchID, nonce, sign, timestamp, c.SerialNo), nil
+}
+
+// Sign 使用商户私钥签名(SHA256 with RSA)
+func (c *WechatV3Client) Sign(message string) (string, error) {
+	hash := sha256.Sum256([]byte(message))
+	signByte, err := rsa.SignPKCS1v15(rand.Reader, c.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signByte), nil
+}
+
+// VerifyResponse 使用平台证书验证应答或通知签名
+func (c *WechatV3Client) VerifyResponse(header http.Header, body []byte) error {
+	if c.PlatformCerts == nil {
+		return errors.New("WechatV3: PlatformCerts not configured")
+	}
+	serialNo := header.Get("Wechatpay-Serial")
+	cert, err := c.PlatformCerts.Certificate(c.MchID, serialNo)
+	if err != nil {
+		return err
+	}
+	return WechatV3Verify(cert, header, body)
+}
+
+// ParseNotify 验证通知签名并解密通知内容到out
+func (c *WechatV3Client) ParseNotify(header http.Header, body []byte, out interface{}) (*common.WeChatV3Notify, error) {
+	err := c.VerifyResponse(header, body)
+	if err != nil {
+		return nil, err
+	}
+	var notify common.WeChatV3Notify
+	err = json.Unmarshal(body, &notify)
+	if err != nil {
+		return nil, errors.New("json.Unmarshal: " + err.Error())
+	}
+	if notify.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return &notify, errors.New("WechatV3: unknown algorithm " + notify.Resource.Algorithm)
+	}
+	plain, err := WechatV3Decrypt(c.APIv3Key, notify.Resource.Nonce, notify.Resource.AssociatedData, notify.Resource.Ciphertext)
+	if err != nil {
+		return &notify, err
+	}
+	err = json.Unmarshal(plain, out)
+	if err != nil {
+		return &notify, errors.New("json.Unmarshal: " + err.Error())
+	}
+	return &notify, nil
+}
+
+// WechatV3Verify 使用证书验证应答签名
+func WechatV3Verify(cert *x509.Certificate, header http.Header, body []byte) error {
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("WechatV3Verify: certificate is not rsa")
+	}
+	timestamp := header.Get("Wechatpay-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("WechatV3Verify: bad Wechatpay-Timestamp " + timestamp)
+	}
+	if d := util.Now().Unix() - ts; d > 300 || d < -300 {
+		return errors.New("WechatV3Verify: timestamp expired")
+	}
+	signByte, err := base64.StdEncoding.DecodeString(header.Get("Wechatpay-Signature"))
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(wechatV3Message(timestamp, header.Get("Wechatpay-Nonce"), string(body))))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signByte)
+}
+
+// WechatV3Decrypt 使用APIv3密钥解密(AEAD_AES_256_GCM)
+func WechatV3Decrypt(apiV3Key, nonce, associatedData, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
+
+// wechatV3Message 构造签名串, 每行以\n结尾
+func wechatV3Message(lines ...string) string {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
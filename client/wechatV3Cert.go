@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+)
+
+const (
+	wechatV3CertRefreshInterval = 12 * time.Hour
+	wechatV3CertRetryInterval   = time.Minute
+)
+
+var defaultWechatV3CertManager = NewWechatV3CertManager(0)
+
+// DefaultWechatV3CertManager 默认平台证书管理器, InitWxV3Client时未配置PlatformCerts的客户端自动加入
+func DefaultWechatV3CertManager() *WechatV3CertManager {
+	return defaultWechatV3CertManager
+}
+
+// WechatV3CertManager 平台证书下载及自动更新, 按商户号管理, 可在多个商户间共享
+type WechatV3CertManager struct {
+	RefreshInterval time.Duration // 定时更新间隔
+
+	mu        sync.Mutex
+	merchants map[string]*wechatV3Merchant
+}
+
+// wechatV3Merchant 单个商户的平台证书
+type wechatV3Merchant struct {
+	mu        sync.Mutex
+	client    *WechatV3Client
+	certs     map[string]*x509.Certificate
+	updatedAt time.Time
+	triedAt   time.Time
+}
+
+// NewWechatV3CertManager 新建平台证书管理器, refreshInterval为0时默认12小时
+func NewWechatV3CertManager(refreshInterval time.Duration) *WechatV3CertManager {
+	if refreshInterval <= 0 {
+		refreshInterval = wechatV3CertRefreshInterval
+	}
+	return &WechatV3CertManager{
+		RefreshInterval: refreshInterval,
+		merchants:       make(map[string]*wechatV3Merchant),
+	}
+}
+
+// Add 加入商户, 并将客户端的平台证书来源设为本管理器. 同一商户号只保留第一个客户端用于下载
+func (cm *WechatV3CertManager) Add(c *WechatV3Client) {
+	cm.mu.Lock()
+	if _, ok := cm.merchants[c.MchID]; !ok {
+		cm.merchants[c.MchID] = &wechatV3Merchant{client: c, certs: make(map[string]*x509.Certificate)}
+	}
+	cm.mu.Unlock()
+	c.PlatformCerts = cm
+}
+
+// Certificate 按序列号获取平台证书, 证书过期未更新或出现未知序列号时重新下载
+func (cm *WechatV3CertManager) Certificate(mchID, serialNo string) (*x509.Certificate, error) {
+	mer, err := cm.merchant(mchID)
+	if err != nil {
+		return nil, err
+	}
+
+	mer.mu.Lock()
+	defer mer.mu.Unlock()
+	cert, ok := mer.certs[serialNo]
+	now := time.Now()
+	if ok && now.Sub(mer.updatedAt) < cm.RefreshInterval {
+		return cert, nil
+	}
+	if now.Sub(mer.triedAt) >= wechatV3CertRetryInterval {
+		err = cm.refresh(mer)
+	}
+	if cert, ok := mer.certs[serialNo]; ok {
+		return cert, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, errors.New("WechatV3CertManager: unknown serial " + serialNo)
+}
+
+// Refresh 立即下载商户的平台证书
+func (cm *WechatV3CertManager) Refresh(mchID string) error {
+	mer, err := cm.merchant(mchID)
+	if err != nil {
+		return err
+	}
+	mer.mu.Lock()
+	defer mer.mu.Unlock()
+	return cm.refresh(mer)
+}
+
+// Start 按RefreshInterval定时更新全部商户的平台证书, 直到stop关闭
+func (cm *WechatV3CertManager) Start(stop <-chan struct{}) {
+	ticker := time.NewTicker(cm.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cm.mu.Lock()
+			var mchIDs []string
+			for mchID := range cm.merchants {
+				mchIDs = append(mchIDs, mchID)
+			}
+			cm.mu.Unlock()
+			for _, mchID := range mchIDs {
+				cm.Refresh(mchID)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (cm *WechatV3CertManager) merchant(mchID string) (*wechatV3Merchant, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	mer, ok := cm.merchants[mchID]
+	if !ok {
+		return nil, errors.New("WechatV3CertManager: unknown mchid " + mchID)
+	}
+	return mer, nil
+}
+
+// refresh 下载并解密平台证书, 用新证书验证应答签名后替换缓存
+func (cm *WechatV3CertManager) refresh(mer *wechatV3Merchant) error {
+	mer.triedAt = time.Now()
+	body, header, err := mer.client.request(context.Background(), "GET", "/v3/certificates", nil)
+	if err != nil {
+		return err
+	}
+
+	var re struct {
+		Data []struct {
+			SerialNo           string                  `json:"serial_no"`
+			EffectiveTime      string                  `json:"effective_time"`
+			ExpireTime         string                  `json:"expire_time"`
+			EncryptCertificate common.WeChatV3Resource `json:"encrypt_certificate"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &re)
+	if err != nil {
+		return errors.New("json.Unmarshal: " + err.Error())
+	}
+
+	certs := make(map[string]*x509.Certificate)
+	for _, item := range re.Data {
+		enc := item.EncryptCertificate
+		plain, err := WechatV3Decrypt(mer.client.APIv3Key, enc.Nonce, enc.AssociatedData, enc.Ciphertext)
+		if err != nil {
+			return errors.New("WechatV3CertManager decrypt: " + err.Error())
+		}
+		block, _ := pem.Decode(plain)
+		if block == nil {
+			return errors.New("WechatV3CertManager: certificate pem decode error")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(cert.NotAfter) {
+			continue
+		}
+		certs[item.SerialNo] = cert
+	}
+
+	cert, ok := certs[header.Get("Wechatpay-Serial")]
+	if !ok {
+		cert, ok = mer.certs[header.Get("Wechatpay-Serial")]
+	}
+	if !ok {
+		return errors.New("WechatV3CertManager: response signed by unknown serial " + header.Get("Wechatpay-Serial"))
+	}
+	err = WechatV3Verify(cert, header, body)
+	if err != nil {
+		return err
+	}
+
+	mer.certs = certs
+	mer.updatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWechatV3CertManager(t *testing.T) {
+	apiV3Key := "0123456789abcdef0123456789abcdef"
+	merchantKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	platformKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	platformCert := newTestCert(t, platformKey, 7)
+	platform := &WechatV3Client{PrivateKey: platformKey}
+
+	var downloads int
+	transport := HTTPSC.Transport
+	defer func() { HTTPSC.Transport = transport }()
+	HTTPSC.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		downloads++
+		block, _ := aes.NewCipher([]byte(apiV3Key))
+		gcm, _ := cipher.NewGCM(block)
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: platformCert.Raw})
+		body, _ := json.Marshal(map[string]interface{}{
+			"data": []interface{}{map[string]interface{}{
+				"serial_no": "7",
+				"encrypt_certificate": map[string]string{
+					"algorithm":       "AEAD_AES_256_GCM",
+					"nonce":           "abcdefghijkl",
+					"associated_data": "certificate",
+					"ciphertext":      base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte("abcdefghijkl"), certPEM, []byte("certificate"))),
+				},
+			}},
+		})
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		sign, _ := platform.Sign(wechatV3Message(timestamp, "n", string(body)))
+		header := http.Header{}
+		header.Set("Wechatpay-Serial", "7")
+		header.Set("Wechatpay-Timestamp", timestamp)
+		header.Set("Wechatpay-Nonce", "n")
+		header.Set("Wechatpay-Signature", sign)
+		return &http.Response{StatusCode: 200, Header: header, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+	})
+
+	cm := NewWechatV3CertManager(time.Hour)
+	c := &WechatV3Client{MchID: "1900000001", SerialNo: "1", PrivateKey: merchantKey, APIv3Key: apiV3Key}
+	cm.Add(c)
+	if c.PlatformCerts != cm {
+		t.Fatal("Add did not set PlatformCerts")
+	}
+
+	for i := 0; i < 2; i++ {
+		cert, err := cm.Certificate("1900000001", "7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cert.Equal(platformCert) {
+			t.Fatal("unexpected certificate")
+		}
+	}
+	if downloads != 1 {
+		t.Fatalf("downloads = %d, want 1", downloads)
+	}
+
+	// 未知序列号在重试间隔内不会重复下载
+	if _, err := cm.Certificate("1900000001", "8"); err == nil {
+		t.Fatal("Certificate accepted unknown serial")
+	}
+	if downloads != 1 {
+		t.Fatalf("downloads = %d, want 1", downloads)
+	}
+	if _, err := cm.Certificate("1900000002", "7"); err == nil {
+		t.Fatal("Certificate accepted unknown mchid")
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, key *rsa.PrivateKey, serial int64) *x509.Certificate {
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "gopay test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestWechatV3SignAndVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newTestCert(t, key, 1)
+	c := &WechatV3Client{MchID: "1900000001", PrivateKey: key, PlatformCerts: WechatV3Certs{"1": cert}}
+
+	body := []byte(`{"code_url":"weixin://wxpay/bizpayurl?pr=test"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	sign, err := c.Sign(wechatV3Message(timestamp, "nonce", string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("Wechatpay-Serial", "1")
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", "nonce")
+	header.Set("Wechatpay-Signature", sign)
+	if err := c.VerifyResponse(header, body); err != nil {
+		t.Fatalf("VerifyResponse: %v", err)
+	}
+
+	if err := c.VerifyResponse(header, []byte(`{}`)); err == nil {
+		t.Fatal("VerifyResponse accepted tampered body")
+	}
+	header.Set("Wechatpay-Serial", "2")
+	if err := c.VerifyResponse(header, body); err == nil {
+		t.Fatal("VerifyResponse accepted unknown serial")
+	}
+}
+
+func TestWechatV3Decrypt(t *testing.T) {
+	apiV3Key := "0123456789abcdef0123456789abcdef"
+	nonce := "abcdefghijkl"
+	plain := `{"out_trade_no":"1217752501201407033233368018","trade_state":"SUCCESS"}`
+
+	block, _ := aes.NewCipher([]byte(apiV3Key))
+	gcm, _ := cipher.NewGCM(block)
+	ciphertext := base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte(nonce), []byte(plain), []byte("transaction")))
+
+	re, err := WechatV3Decrypt(apiV3Key, nonce, "transaction", ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(re) != plain {
+		t.Fatalf("got %s, want %s", re, plain)
+	}
+	if _, err := WechatV3Decrypt(apiV3Key, nonce, "certificate", ciphertext); err == nil {
+		t.Fatal("WechatV3Decrypt accepted wrong associated data")
+	}
+}
@@ -64,33 +64,43 @@ type AliQueryResult struct {
 	DiscountGoodsDetail string     `json:"discount_goods_detail"`
 	IndustrySepcDetail  string     `json:"industry_sepc_detail"`
 	PassbackParams      string     `json:"passback_params"`
+	TransCurrency       string     `json:"trans_currency"`    // 标价币种
+	SettleCurrency      string     `json:"settle_currency"`   // 结算币种
+	SettleAmount        string     `json:"settle_amount"`     // 结算币种金额
+	SettleTransRate     string     `json:"settle_trans_rate"` // 结算币种兑标价币种汇率
+	PayCurrency         string     `json:"pay_currency"`      // 支付币种
+	PayAmount           string     `json:"pay_amount"`        // 支付币种金额
+	TransPayRate        string     `json:"trans_pay_rate"`    // 标价币种兑支付币种汇率
 }
 
-// AliWebQueryResult ...
-type AliWebQueryResult struct {
-	IsSuccess string `xml:"is_success"`
-	ErrorMsg  string `xml:"error"`
-	SignType  string `xml:"sign_type"`
-	Sign      string `xml:"sign"`
-	Response  struct {
-		Trade struct {
-			BuyerEmail          string `xml:"buyer_email"`
-			BuyerID             string `xml:"buyer_id"`
-			SellerID            string `xml:"seller_id"`
-			TradeStatus         string `xml:"trade_status"`
-			IsTotalFeeAdjust    string `xml:"is_total_fee_adjust"`
-			OutTradeNum         string `xml:"out_trade_no"`
-			Subject             string `xml:"subject"`
-			FlagTradeLocked     string `xml:"flag_trade_locked"`
-			Body                string `xml:"body"`
-			GmtCreate           string `xml:"gmt_create"`
-			GmtPayment          string `xml:"gmt_payment"`
-			GmtLastModifiedTime string `xml:"gmt_last_modified_time"`
-			SellerEmail         string `xml:"seller_email"`
-			TotalFee            string `xml:"total_fee"`
-			TradeNum            string `xml:"trade_no"`
-		} `xml:"trade"`
-	} `xml:"response"`
+// AliTradeQuery 支付宝订单查询(alipay.trade.query)参数, TradeNo和OutTradeNo至少传一个
+type AliTradeQuery struct {
+	TradeNo      string   `json:"trade_no,omitempty"`      // 支付宝交易号, 优先使用
+	OutTradeNo   string   `json:"out_trade_no,omitempty"`  // 商户订单号
+	QueryOptions []string `json:"query_options,omitempty"` // 额外返回的信息, 如fund_bill_list, voucher_detail_list
+}
+
+// AliTradeFundBill 交易支付使用的资金渠道
+type AliTradeFundBill struct {
+	FundChannel string `json:"fund_channel"`
+	Amount      string `json:"amount"`
+	RealAmount  string `json:"real_amount"`
+	FundType    string `json:"fund_type"`
+}
+
+// AliVoucherDetail 交易使用的券
+type AliVoucherDetail struct {
+	ID                         string `json:"id"`
+	Name                       string `json:"name"`
+	Type                       string `json:"type"`
+	Amount                     string `json:"amount"`
+	MerchantContribute         string `json:"merchant_contribute"`
+	OtherContribute            string `json:"other_contribute"`
+	Memo                       string `json:"memo"`
+	TemplateID                 string `json:"template_id"`
+	PurchaseBuyerContribute    string `json:"purchase_buyer_contribute"`
+	PurchaseMerchantContribute string `json:"purchase_merchant_contribute"`
+	PurchaseAntContribute      string `json:"purchase_ant_contribute"`
 }
 
 // AliWebAppQueryResult ...
@@ -118,6 +128,17 @@ type AliWebAppQueryResult struct {
 		BuyerUserID         string `json:"buyer_user_id"`
 		DiscountGoodsDetail string `json:"discount_goods_detail"`
 		IndustrySepcDetail  string `json:"industry_sepc_detail"`
+		PassbackParams      string `json:"passback_params"`
+		TransCurrency       string `json:"trans_currency"`
+		SettleCurrency      string `json:"settle_currency"`
+		SettleAmount        string `json:"settle_amount"`
+		SettleTransRate     string `json:"settle_trans_rate"`
+		PayCurrency         string `json:"pay_currency"`
+		PayAmount           string `json:"pay_amount"`
+		TransPayRate        string `json:"trans_pay_rate"`
+
+		FundBillList      []AliTradeFundBill `json:"fund_bill_list"`      // query_options含fund_bill_list时返回
+		VoucherDetailList []AliVoucherDetail `json:"voucher_detail_list"` // query_options含voucher_detail_list时返回
 	} `json:"alipay_trade_query_response"`
 	Sign string `json:"sign"`
 }
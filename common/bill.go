@@ -0,0 +1,73 @@
+package common
+
+import "time"
+
+// BillRecord 对账单中的一笔交易或退款, 金额为最小货币单位(分)
+type BillRecord struct {
+	TradeTime     time.Time // 交易时间
+	AppID         string    // 公众账号ID
+	MchID         string    // 商户号
+	SubMchID      string    // 特约商户号
+	DeviceInfo    string    // 设备号
+	TransactionID string    // 渠道交易号
+	TradeNum      string    // 商户订单号
+	Payer         string    // 用户标识, 微信openid
+	TradeType     string    // 交易类型, 如JSAPI, APP
+	TradeState    string    // 渠道交易状态, 如SUCCESS, REFUND
+	BankType      string    // 付款银行
+	Currency      string    // 货币种类
+	SettlementFee int64     // 应结订单金额
+	CouponFee     int64     // 代金券金额
+	TotalFee      int64     // 订单金额
+
+	RefundApplyTime   time.Time // 退款申请时间, 仅退款账单
+	RefundSuccessTime time.Time // 退款成功时间, 仅退款账单
+	RefundID          string    // 渠道退款单号
+	OutRefundNo       string    // 商户退款单号
+	RefundFee         int64     // 退款金额
+	CouponRefundFee   int64     // 充值券退款金额
+	ApplyRefundFee    int64     // 申请退款金额
+	RefundType        string    // 退款类型
+	RefundStatus      string    // 退款状态
+
+	Subject    string // 商品名称
+	Attach     string // 商户数据包
+	ServiceFee int64  // 手续费, 按分四舍五入
+	Rate       string // 费率, 如0.60%
+	RateRemark string // 费率备注
+}
+
+// BillSummary 对账单汇总, 金额为最小货币单位(分)
+type BillSummary struct {
+	TradeCount      int64 `json:"tradeCount"`      // 总交易单数
+	SettlementFee   int64 `json:"settlementFee"`   // 应结订单总金额
+	RefundFee       int64 `json:"refundFee"`       // 退款总金额
+	CouponRefundFee int64 `json:"couponRefundFee"` // 充值券退款总金额
+	ServiceFee      int64 `json:"serviceFee"`      // 手续费总金额
+	TotalFee        int64 `json:"totalFee"`        // 订单总金额
+	ApplyRefundFee  int64 `json:"applyRefundFee"`  // 申请退款总金额
+}
+
+// FundFlowRecord 资金账单中的一笔资金变动, 金额为最小货币单位(分)
+type FundFlowRecord struct {
+	Time          time.Time // 记账时间
+	TransactionID string    // 微信支付业务单号
+	FlowID        string    // 资金流水单号
+	BizName       string    // 业务名称, 如退款, 交易
+	BizType       string    // 业务类型
+	Direction     string    // 收支类型: 收入或支出
+	Amount        int64     // 收支金额
+	Balance       int64     // 账户结余
+	Applicant     string    // 资金变更提交申请人
+	Remark        string    // 备注
+	VoucherNo     string    // 业务凭证号
+}
+
+// FundFlowSummary 资金账单汇总, 金额为最小货币单位(分)
+type FundFlowSummary struct {
+	Count         int64 `json:"count"`         // 资金流水总笔数
+	IncomeCount   int64 `json:"incomeCount"`   // 收入笔数
+	IncomeAmount  int64 `json:"incomeAmount"`  // 收入金额
+	ExpenseCount  int64 `json:"expenseCount"`  // 支出笔数
+	ExpenseAmount int64 `json:"expenseAmount"` // 支出金额
+}
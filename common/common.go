@@ -1,6 +1,8 @@
 package common
 
-import ()
+import (
+	"time"
+)
 
 // PayClient 支付客户端接口
 type PayClient interface {
@@ -21,6 +23,18 @@ type Charge struct {
 	ShowURL     string  `json:"showURL,omitempty"`
 	Describe    string  `json:"describe,omitempty"`
 	OpenID      string  `json:"openid,omitempty"`
+	ClientIP    string  `json:"clientIP,omitempty"` // 付款人IP, 为空时使用本机IP
+
+	ExpireAt time.Time     `json:"expireAt,omitempty"` // 订单失效时间, 优先于Timeout
+	Timeout  time.Duration `json:"timeout,omitempty"`  // 订单有效时长, 从下单时起算
+
+	Metadata map[string]string `json:"metadata,omitempty"` // 业务附加数据, 在通知和查询结果中原样返回
+
+	Goods                []GoodsItem `json:"goods,omitempty"`                // 商品明细, 单品优惠用
+	GoodsTag             string      `json:"goodsTag,omitempty"`             // 订单优惠标记, 仅微信使用
+	UndiscountableAmount float64     `json:"undiscountableAmount,omitempty"` // 不可打折金额(元), 仅支付宝使用
+
+	Currency string `json:"currency,omitempty"` // 标价币种, ISO 4217, 默认CNY. MoneyFee按该币种计
 }
 
 //PayCallback 支付返回
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"encoding/json"
+)
+
+// GoodsItem 商品明细, 用于单品优惠
+type GoodsItem struct {
+	ID       string  `json:"id"`                 // 商户商品编码
+	Name     string  `json:"name"`               // 商品名称
+	Quantity int64   `json:"quantity"`           // 数量
+	Price    float64 `json:"price"`              // 单价(元)
+	Category string  `json:"category,omitempty"` // 商品类目, 仅支付宝使用
+}
+
+// WechatPromotion 微信单品优惠信息
+type WechatPromotion struct {
+	PromotionID        string                 `json:"promotion_id"`
+	Name               string                 `json:"name"`
+	Scope              string                 `json:"scope"` // GLOBAL全场优惠, SINGLE单品优惠
+	Type               string                 `json:"type"`  // COUPON充值型, DISCOUNT免充值型
+	Amount             int64                  `json:"amount"`
+	ActivityID         string                 `json:"activity_id"`
+	WxpayContribute    int64                  `json:"wxpay_contribute"`
+	MerchantContribute int64                  `json:"merchant_contribute"`
+	OtherContribute    int64                  `json:"other_contribute"`
+	GoodsDetail        []WechatPromotionGoods `json:"goods_detail"`
+}
+
+// WechatPromotionGoods 微信单品优惠商品
+type WechatPromotionGoods struct {
+	GoodsID        string `json:"goods_id"`
+	GoodsRemark    string `json:"goods_remark"`
+	Quantity       int64  `json:"quantity"`
+	Price          int64  `json:"price"`
+	DiscountAmount int64  `json:"discount_amount"`
+}
+
+// WeChatV3Promotion v3优惠信息
+type WeChatV3Promotion struct {
+	CouponID            string                   `json:"coupon_id"`
+	Name                string                   `json:"name"`
+	Scope               string                   `json:"scope"`
+	Type                string                   `json:"type"`
+	Amount              int64                    `json:"amount"`
+	StockID             string                   `json:"stock_id"`
+	WechatpayContribute int64                    `json:"wechatpay_contribute"`
+	MerchantContribute  int64                    `json:"merchant_contribute"`
+	OtherContribute     int64                    `json:"other_contribute"`
+	Currency            string                   `json:"currency"`
+	GoodsDetail         []WeChatV3PromotionGoods `json:"goods_detail"`
+}
+
+// WeChatV3PromotionGoods v3单品优惠商品
+type WeChatV3PromotionGoods struct {
+	GoodsID        string `json:"goods_id"`
+	Quantity       int64  `json:"quantity"`
+	UnitPrice      int64  `json:"unit_price"`
+	DiscountAmount int64  `json:"discount_amount"`
+	GoodsRemark    string `json:"goods_remark"`
+}
+
+// AliDiscountGoods 支付宝单品券优惠的商品
+type AliDiscountGoods struct {
+	GoodsID        string `json:"goods_id"`
+	GoodsName      string `json:"goods_name"`
+	DiscountAmount string `json:"discount_amount"`
+	VoucherID      string `json:"voucher_id"`
+}
+
+// Promotions 解析promotion_detail
+func (r WechatResultData) Promotions() ([]WechatPromotion, error) {
+	var re struct {
+		PromotionDetail []WechatPromotion `json:"promotion_detail"`
+	}
+	if r.PromotionDetail == "" {
+		return nil, nil
+	}
+	err := json.Unmarshal([]byte(r.PromotionDetail), &re)
+	return re.PromotionDetail, err
+}
+
+// DiscountGoods 解析discount_goods_detail
+func (r AliQueryResult) DiscountGoods() ([]AliDiscountGoods, error) {
+	return parseAliDiscountGoods(r.DiscountGoodsDetail)
+}
+
+// DiscountGoods 解析discount_goods_detail
+func (r AliWebAppQueryResult) DiscountGoods() ([]AliDiscountGoods, error) {
+	return parseAliDiscountGoods(r.AlipayTradeQueryResponse.DiscountGoodsDetail)
+}
+
+func parseAliDiscountGoods(s string) ([]AliDiscountGoods, error) {
+	var re []AliDiscountGoods
+	if s == "" {
+		return re, nil
+	}
+	err := json.Unmarshal([]byte(s), &re)
+	return re, err
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"net/url"
+	"strings"
+)
+
+// EncodeMetadata 业务附加数据编码为k=v&k=v形式, 按key排序
+func EncodeMetadata(m map[string]string) string {
+	var values = url.Values{}
+	for k, v := range m {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// DecodeMetadata 解码EncodeMetadata的结果, 兼容再经过一次url编码的内容(支付宝passback_params)
+func DecodeMetadata(s string) (map[string]string, error) {
+	var m = make(map[string]string)
+	if s == "" {
+		return m, nil
+	}
+	if !strings.Contains(s, "=") {
+		var err error
+		s, err = url.QueryUnescape(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	for k := range values {
+		m[k] = values.Get(k)
+	}
+	return m, nil
+}
+
+// Metadata 解码attach中的业务附加数据
+func (r WechatResultData) Metadata() (map[string]string, error) {
+	return DecodeMetadata(r.Attach)
+}
+
+// Metadata 解码passback_params中的业务附加数据
+func (r AliQueryResult) Metadata() (map[string]string, error) {
+	return DecodeMetadata(r.PassbackParams)
+}
+
+// Metadata 解码passback_params中的业务附加数据
+func (r AliWebAppQueryResult) Metadata() (map[string]string, error) {
+	return DecodeMetadata(r.AlipayTradeQueryResponse.PassbackParams)
+}
+
+// Metadata 解码attach中的业务附加数据
+func (t WeChatV3Transaction) Metadata() (map[string]string, error) {
+	return DecodeMetadata(t.Attach)
+}
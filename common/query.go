@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"time"
+)
+
+// TradeState 统一的交易状态
+type TradeState int
+
+// 交易状态
+const (
+	TradeStateUnknown  TradeState = iota // 无法识别的渠道状态
+	TradeStateNotPay                     // 未支付
+	TradeStatePaying                     // 用户支付中
+	TradeStateSuccess                    // 支付成功
+	TradeStateClosed                     // 已关闭
+	TradeStateRefunded                   // 转入退款
+	TradeStateRevoked                    // 已撤销
+	TradeStatePayError                   // 支付失败
+)
+
+var tradeStateNames = [...]string{"UNKNOWN", "NOTPAY", "PAYING", "SUCCESS", "CLOSED", "REFUNDED", "REVOKED", "PAYERROR"}
+
+func (s TradeState) String() string {
+	if s < 0 || int(s) >= len(tradeStateNames) {
+		return tradeStateNames[TradeStateUnknown]
+	}
+	return tradeStateNames[s]
+}
+
+// QueryResult 统一的订单查询结果
+type QueryResult struct {
+	PayMethod     int64
+	TradeNum      string            // 商户订单号
+	TransactionID string            // 渠道交易号
+	TradeState    TradeState        // 交易状态
+	ProviderState string            // 渠道原始状态, 如SUCCESS, TRADE_SUCCESS
+	Currency      string            // 标价币种
+	TotalFee      int64             // 订单金额, 最小货币单位(分)
+	PaidFee       int64             // 用户实付金额, 最小货币单位(分), 未支付为0
+	Payer         string            // 付款人, 微信openid或支付宝买家ID
+	PaidAt        time.Time         // 支付完成时间, 未支付为零值
+	Metadata      map[string]string // 下单时的业务附加数据, 解码自attach或passback_params
+	Raw           interface{}       // 渠道原始结果, 如WeChatQueryResult, AliWebAppQueryResult
+}
+
+// QueryClient 支持统一订单查询的客户端
+type QueryClient interface {
+	Query(ctx context.Context, tradeNum string) (*QueryResult, error)
+}
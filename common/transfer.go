@@ -0,0 +1,166 @@
+package common
+
+import "time"
+
+// Transfer 企业付款到零钱参数
+type Transfer struct {
+	TradeNum   string // 商户付款单号(partner_trade_no), 幂等, 重试时必须使用原单号
+	OpenID     string // 收款用户openid
+	Amount     int64  // 付款金额(分)
+	Desc       string // 付款备注
+	UserName   string // 收款用户真实姓名, 非空时校验姓名(FORCE_CHECK)
+	ClientIP   string // 调用接口的机器IP, 为空时使用本机IP
+	DeviceInfo string // 设备号
+}
+
+// TransferStatus 企业付款状态
+type TransferStatus string
+
+// 企业付款状态
+const (
+	TransferSuccess    TransferStatus = "SUCCESS"    // 转账成功
+	TransferFailed     TransferStatus = "FAILED"     // 转账失败
+	TransferProcessing TransferStatus = "PROCESSING" // 处理中
+)
+
+// WeChatTransferResult 企业付款结果
+type WeChatTransferResult struct {
+	WechatBaseResult
+	MchAppID       string `xml:"mch_appid"`
+	MchID          string `xml:"mchid"`
+	DeviceInfo     string `xml:"device_info"`
+	NonceStr       string `xml:"nonce_str"`
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	PaymentNo      string `xml:"payment_no"`   // 微信付款单号
+	PaymentTime    string `xml:"payment_time"` // 付款成功时间
+}
+
+// WeChatTransferInfo 企业付款查询结果
+type WeChatTransferInfo struct {
+	WechatBaseResult
+	ResultCode     string         `xml:"result_code"`
+	ErrCode        string         `xml:"err_code"`
+	ErrCodeDes     string         `xml:"err_code_des"`
+	PartnerTradeNo string         `xml:"partner_trade_no"`
+	AppID          string         `xml:"appid"`
+	MchID          string         `xml:"mch_id"`
+	DetailID       string         `xml:"detail_id"` // 微信付款单号
+	Status         TransferStatus `xml:"status"`
+	Reason         string         `xml:"reason"` // 失败原因
+	OpenID         string         `xml:"openid"`
+	TransferName   string         `xml:"transfer_name"` // 收款用户姓名
+	PaymentAmount  int64          `xml:"payment_amount"`
+	TransferTime   string         `xml:"transfer_time"` // 发起转账时间
+	PaymentTime    string         `xml:"payment_time"`  // 付款成功时间
+	Desc           string         `xml:"desc"`
+}
+
+// 付款到银行卡的银行退票状态
+const TransferBankFail TransferStatus = "BANK_FAIL"
+
+// BankTransfer 企业付款到银行卡参数
+type BankTransfer struct {
+	TradeNum string // 商户付款单号(partner_trade_no), 幂等, 重试时必须使用原单号
+	BankNo   string // 收款方银行卡号, 提交时加密
+	TrueName string // 收款方用户名, 提交时加密
+	BankCode string // 收款方开户行, 见constant.BANK_*
+	Amount   int64  // 付款金额(分)
+	Desc     string // 付款说明
+}
+
+// WeChatBankTransferResult 企业付款到银行卡结果, 受理成功不代表到账, 需查询最终状态
+type WeChatBankTransferResult struct {
+	WechatBaseResult
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	MchID          string `xml:"mch_id"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	Amount         int64  `xml:"amount"`
+	NonceStr       string `xml:"nonce_str"`
+	PaymentNo      string `xml:"payment_no"` // 微信付款单号
+	CmmsAmt        int64  `xml:"cmms_amt"`   // 手续费(分)
+}
+
+// WeChatBankTransferInfo 企业付款到银行卡查询结果
+type WeChatBankTransferInfo struct {
+	WechatBaseResult
+	ResultCode     string         `xml:"result_code"`
+	ErrCode        string         `xml:"err_code"`
+	ErrCodeDes     string         `xml:"err_code_des"`
+	MchID          string         `xml:"mch_id"`
+	PartnerTradeNo string         `xml:"partner_trade_no"`
+	PaymentNo      string         `xml:"payment_no"`
+	BankNoMD5      string         `xml:"bank_no_md5"`
+	TrueNameMD5    string         `xml:"true_name_md5"`
+	Amount         int64          `xml:"amount"`
+	Status         TransferStatus `xml:"status"` // PROCESSING, SUCCESS, FAILED, BANK_FAIL
+	CmmsAmt        int64          `xml:"cmms_amt"`
+	CreateTime     string         `xml:"create_time"`
+	PaySuccTime    string         `xml:"pay_succ_time"`
+	Reason         string         `xml:"reason"` // 失败原因
+}
+
+// AliTransfer 支付宝单笔转账到支付宝账户参数
+type AliTransfer struct {
+	TradeNum  string // 商户转账单号(out_biz_no), 幂等, 重试时必须使用原单号
+	Amount    int64  // 转账金额(分)
+	Title     string // 转账业务标题
+	PayeeID   string // 收款方支付宝用户号(2088开头)或登录号
+	PayeeType string // 收款方标识类型, 默认client.AliPayeeUserID
+	PayeeName string // 收款方真实姓名, 登录号必填, 用户号填写时校验
+	Remark    string // 业务备注
+}
+
+// AliTransferResponse alipay.fund.trans.uni.transfer应答
+type AliTransferResponse struct {
+	OutBizNo       string `json:"out_biz_no"`
+	OrderID        string `json:"order_id"`          // 支付宝转账订单号
+	PayFundOrderID string `json:"pay_fund_order_id"` // 支付宝支付资金流水号
+	Status         string `json:"status"`
+	TransDate      string `json:"trans_date"`
+}
+
+// AliTransferQueryResponse alipay.fund.trans.common.query应答
+type AliTransferQueryResponse struct {
+	OrderID        string `json:"order_id"`
+	PayFundOrderID string `json:"pay_fund_order_id"`
+	OutBizNo       string `json:"out_biz_no"`
+	TransAmount    string `json:"trans_amount"`
+	Status         string `json:"status"` // SUCCESS, DEALING, WAIT_PAY, REFUND, FAIL, CLOSED, INIT
+	PayDate        string `json:"pay_date"`
+	ArrivalTimeEnd string `json:"arrival_time_end"`
+	OrderFee       string `json:"order_fee"`
+	ErrorCode      string `json:"error_code"`
+	FailReason     string `json:"fail_reason"`
+	SubStatus      string `json:"sub_status"`
+}
+
+// AliAccountQueryResponse alipay.fund.account.query应答
+type AliAccountQueryResponse struct {
+	AvailableAmount string `json:"available_amount"`
+	FreezeAmount    string `json:"freeze_amount"`
+}
+
+// TransferResult 统一的转账结果
+type TransferResult struct {
+	TradeNum      string         // 商户转账单号
+	PaymentNo     string         // 渠道转账单号
+	Status        TransferStatus // 转账状态
+	ProviderState string         // 渠道原始状态, 如SUCCESS, DEALING
+	Amount        int64          // 转账金额(分)
+	Fee           int64          // 手续费(分)
+	PaidAt        time.Time      // 转账成功时间, 未成功为零值
+	Reason        string         // 失败原因
+	Raw           interface{}    // 渠道原始结果, 如AliTransferResponse, AliTransferQueryResponse
+}
+
+// AccountBalance 账户余额, 金额为最小货币单位(分)
+type AccountBalance struct {
+	Available int64       // 可用余额
+	Freeze    int64       // 冻结金额
+	Raw       interface{} // 渠道原始结果, 如AliAccountQueryResponse
+}
@@ -51,6 +51,9 @@ type WechatResultData struct {
 	OutTradeNO    string `xml:"out_trade_no,omitempty"`
 	Attach        string `xml:"attach,omitempty"`
 	TimeEnd       string `xml:"time_end,omitempty"`
+	Rate          int64  `xml:"rate,omitempty"` // 标价币种兑支付币种汇率乘以10^8, 境外支付返回
+	// PromotionDetail 单品优惠信息(JSON), 下单和查询传version=1.0时返回
+	PromotionDetail string `xml:"promotion_detail,omitempty"`
 }
 
 // WeChatPayResult ...
@@ -69,3 +72,35 @@ type WeChatQueryResult struct {
 	TradeState     string `xml:"trade_state"`
 	TradeStateDesc string `xml:"trade_state_desc"`
 }
+
+// WeChatRefundNotify 退款结果通知
+type WeChatRefundNotify struct {
+	WechatBaseResult
+	AppID    string `xml:"appid"`
+	MchID    string `xml:"mch_id"`
+	NonceStr string `xml:"nonce_str"`
+	ReqInfo  string `xml:"req_info"`
+}
+
+// WeChatRefundInfo 退款结果通知req_info解密内容
+type WeChatRefundInfo struct {
+	TransactionID       string `xml:"transaction_id"`
+	OutTradeNo          string `xml:"out_trade_no"`
+	RefundID            string `xml:"refund_id"`
+	OutRefundNo         string `xml:"out_refund_no"`
+	TotalFee            int64  `xml:"total_fee"`
+	SettlementTotalFee  int64  `xml:"settlement_total_fee"`
+	RefundFee           int64  `xml:"refund_fee"`
+	SettlementRefundFee int64  `xml:"settlement_refund_fee"`
+	RefundStatus        string `xml:"refund_status"`
+	SuccessTime         string `xml:"success_time"`
+	RefundRecvAccout    string `xml:"refund_recv_accout"`
+	RefundAccount       string `xml:"refund_account"`
+	RefundRequestSource string `xml:"refund_request_source"`
+}
+
+// WeChatRefundResult 退款结果通知及解密后的退款信息
+type WeChatRefundResult struct {
+	WeChatRefundNotify
+	WeChatRefundInfo
+}
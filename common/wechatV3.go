@@ -0,0 +1,51 @@
+package common
+
+// WeChatV3Amount v3订单金额(单位分)
+type WeChatV3Amount struct {
+	Total         int64  `json:"total"`
+	PayerTotal    int64  `json:"payer_total,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	PayerCurrency string `json:"payer_currency,omitempty"`
+}
+
+// WeChatV3Payer v3支付者
+type WeChatV3Payer struct {
+	OpenID string `json:"openid"`
+}
+
+// WeChatV3Transaction v3订单查询结果及支付通知内容
+type WeChatV3Transaction struct {
+	AppID          string         `json:"appid"`
+	MchID          string         `json:"mchid"`
+	OutTradeNo     string         `json:"out_trade_no"`
+	TransactionID  string         `json:"transaction_id"`
+	TradeType      string         `json:"trade_type"`
+	TradeState     string         `json:"trade_state"`
+	TradeStateDesc string         `json:"trade_state_desc"`
+	BankType       string         `json:"bank_type"`
+	Attach         string         `json:"attach"`
+	SuccessTime    string         `json:"success_time"`
+	Payer          WeChatV3Payer  `json:"payer"`
+	Amount         WeChatV3Amount `json:"amount"`
+
+	PromotionDetail []WeChatV3Promotion `json:"promotion_detail,omitempty"`
+}
+
+// WeChatV3Resource v3通知加密数据
+type WeChatV3Resource struct {
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	OriginalType   string `json:"original_type"`
+	Nonce          string `json:"nonce"`
+}
+
+// WeChatV3Notify v3通知
+type WeChatV3Notify struct {
+	ID           string           `json:"id"`
+	CreateTime   string           `json:"create_time"`
+	EventType    string           `json:"event_type"`
+	ResourceType string           `json:"resource_type"`
+	Resource     WeChatV3Resource `json:"resource"`
+	Summary      string           `json:"summary"`
+}
@@ -0,0 +1,43 @@
+package constant
+
+// 微信企业付款到银行卡的收款方开户行编码
+const (
+	BANK_ICBC  = "1002" // 工商银行
+	BANK_ABC   = "1005" // 农业银行
+	BANK_BOC   = "1026" // 中国银行
+	BANK_CCB   = "1003" // 建设银行
+	BANK_CMB   = "1001" // 招商银行
+	BANK_PSBC  = "1066" // 邮储银行
+	BANK_COMM  = "1020" // 交通银行
+	BANK_SPDB  = "1004" // 浦发银行
+	BANK_CMBC  = "1006" // 民生银行
+	BANK_CIB   = "1009" // 兴业银行
+	BANK_PAB   = "1010" // 平安银行
+	BANK_CITIC = "1021" // 中信银行
+	BANK_HXB   = "1025" // 华夏银行
+	BANK_CGB   = "1027" // 广发银行
+	BANK_CEB   = "1022" // 光大银行
+	BANK_BOB   = "4836" // 北京银行
+	BANK_NBCB  = "1056" // 宁波银行
+)
+
+// BankNames 开户行编码对应的银行名称
+var BankNames = map[string]string{
+	BANK_ICBC:  "工商银行",
+	BANK_ABC:   "农业银行",
+	BANK_BOC:   "中国银行",
+	BANK_CCB:   "建设银行",
+	BANK_CMB:   "招商银行",
+	BANK_PSBC:  "邮储银行",
+	BANK_COMM:  "交通银行",
+	BANK_SPDB:  "浦发银行",
+	BANK_CMBC:  "民生银行",
+	BANK_CIB:   "兴业银行",
+	BANK_PAB:   "平安银行",
+	BANK_CITIC: "中信银行",
+	BANK_HXB:   "华夏银行",
+	BANK_CGB:   "广发银行",
+	BANK_CEB:   "光大银行",
+	BANK_BOB:   "北京银行",
+	BANK_NBCB:  "宁波银行",
+}
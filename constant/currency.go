@@ -0,0 +1,17 @@
+package constant
+
+// 常用币种, ISO 4217
+const (
+	CNY = "CNY"
+	HKD = "HKD"
+	USD = "USD"
+	EUR = "EUR"
+	GBP = "GBP"
+	JPY = "JPY"
+	KRW = "KRW"
+	SGD = "SGD"
+	AUD = "AUD"
+	CAD = "CAD"
+	VND = "VND"
+	IDR = "IDR"
+)
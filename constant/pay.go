@@ -6,4 +6,9 @@ const (
 	WECHAT_WEB
 	WECHAT_APP
 	WECHAT_MINI_PROGRAM
+	WECHAT_V3_JSAPI
+	WECHAT_V3_APP
+	WECHAT_V3_H5
+	WECHAT_V3_NATIVE
+	WECHAT_V3_MINI_PROGRAM
 )
@@ -0,0 +1,497 @@
+package gopaytest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/sulrex/gopay/client"
+)
+
+// SubmitAlipay 模拟支付宝客户端提交下单参数, 参数为AliAppClient返回的orderString或AliWebClient返回的url
+func (s *Server) SubmitAlipay(payload string) error {
+	if i := strings.Index(payload, "?"); i >= 0 {
+		payload = payload[i+1:]
+	}
+	values, err := url.ParseQuery(payload)
+	if err != nil {
+		return err
+	}
+	m := formToMap(values)
+	biz, err := s.alipayRequest(m)
+	if err != nil {
+		return err
+	}
+	return s.alipayPay(m, biz)
+}
+
+func (s *Server) serveAlipay(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m := formToMap(r.Form)
+	method := m["method"]
+	biz, err := s.alipayRequest(m)
+	if err != nil {
+		s.alipayRespond(w, m, aliFail("40002", "Invalid Arguments", "isv.invalid-signature", err.Error()))
+		return
+	}
+
+	switch method {
+	case "alipay.trade.wap.pay", "alipay.trade.app.pay", "alipay.trade.page.pay":
+		if err := s.alipayPay(m, biz); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("<html><body>gopaytest alipay cashier</body></html>"))
+	case "alipay.trade.query":
+		s.alipayRespond(w, m, s.alipayQuery(biz))
+	case "alipay.trade.close":
+		s.alipayRespond(w, m, s.alipayClose(biz))
+	case "alipay.trade.refund":
+		s.alipayRespond(w, m, s.alipayRefund(biz))
+	case "alipay.data.dataservice.bill.downloadurl.query":
+		s.alipayRespond(w, m, s.alipayBillURL(biz))
+	case "alipay.fund.trans.uni.transfer", "alipay.fund.trans.common.query", "alipay.fund.account.query":
+		s.alipayRespond(w, m, s.alipayFund(method, m, biz))
+	default:
+		s.alipayRespond(w, m, aliFail("40004", "Business Failed", "isv.invalid-method", "不存在的方法名"))
+	}
+}
+
+// alipayRequest 验证请求签名并解析biz_content
+func (s *Server) alipayRequest(m map[string]string) (map[string]string, error) {
+	err := rsaVerify(&s.AppKey.PublicKey, m["sign_type"], signContent(m, "sign"), m["sign"])
+	if err != nil {
+		return nil, errors.New("gopaytest: alipay sign: " + err.Error())
+	}
+	if m["app_cert_sn"] != "" && (m["app_cert_sn"] != s.appCertSN || m["alipay_root_cert_sn"] != s.rootCertSN) {
+		return nil, errors.New("gopaytest: alipay cert sn mismatch")
+	}
+	bizContent := m["biz_content"]
+	if m["encrypt_type"] == "AES" {
+		bizContent, err = client.AliDecrypt(s.AlipayAESKey, bizContent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var raw map[string]interface{}
+	err = json.Unmarshal([]byte(bizContent), &raw)
+	if err != nil {
+		return nil, errors.New("gopaytest: biz_content: " + err.Error())
+	}
+	biz := make(map[string]string)
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			biz[k] = v
+		case []interface{}:
+			// 字符串数组(如query_options)以逗号连接
+			var list []string
+			for _, item := range v {
+				if str, ok := item.(string); ok {
+					list = append(list, str)
+				}
+			}
+			biz[k] = strings.Join(list, ",")
+		case map[string]interface{}:
+			// 对象(如payee_info)展开为payee_info.identity
+			for sk, sv := range v {
+				if str, ok := sv.(string); ok {
+					biz[k+"."+sk] = str
+				}
+			}
+		}
+	}
+	return biz, nil
+}
+
+func (s *Server) alipayPay(m, biz map[string]string) error {
+	total, err := yuanToFen(biz["total_amount"])
+	if err != nil {
+		return err
+	}
+	if biz["out_trade_no"] == "" {
+		return errors.New("gopaytest: out_trade_no required")
+	}
+	currency := biz["trans_currency"]
+	if currency == "" {
+		currency = "CNY"
+	}
+	return s.addOrder(&Order{
+		Provider:  Alipay,
+		TradeNum:  biz["out_trade_no"],
+		TotalFee:  total,
+		Status:    "WAIT_BUYER_PAY",
+		Subject:   biz["subject"],
+		Attach:    biz["passback_params"],
+		Currency:  currency,
+		NotifyURL: m["notify_url"],
+		SignType:  m["sign_type"],
+	})
+}
+
+// alipayOrder 按out_trade_no或trade_no查找订单, 调用方需持有锁
+func (s *Server) alipayOrder(biz map[string]string) *Order {
+	if o, ok := s.orders[biz["out_trade_no"]]; ok && o.Provider == Alipay {
+		return o
+	}
+	for _, o := range s.orders {
+		if o.Provider == Alipay && biz["trade_no"] != "" && o.TransactionID == biz["trade_no"] {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) alipayQuery(biz map[string]string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.alipayOrder(biz)
+	if o == nil {
+		return aliFail("40004", "Business Failed", "ACQ.TRADE_NOT_EXIST", "交易不存在")
+	}
+	re := aliSuccess(o)
+	re["trade_status"] = o.Status
+	re["total_amount"] = fenToYuan(o.TotalFee)
+	if o.Attach != "" {
+		re["passback_params"] = o.Attach
+	}
+	if o.Currency != "CNY" {
+		re["trans_currency"] = o.Currency
+	}
+	if !o.PaidAt.IsZero() {
+		re["receipt_amount"] = fenToYuan(o.TotalFee - o.RefundFee)
+		re["buyer_pay_amount"] = fenToYuan(o.TotalFee)
+		re["send_pay_date"] = o.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05")
+		re["buyer_user_id"] = "2088101117955611"
+		re["buyer_logon_id"] = "159****5620"
+		options := strings.Split(biz["query_options"], ",")
+		if contains(options, "fund_bill_list") {
+			re["fund_bill_list"] = []map[string]string{{"fund_channel": "ALIPAYACCOUNT", "amount": fenToYuan(o.TotalFee)}}
+		}
+		if contains(options, "voucher_detail_list") {
+			re["voucher_detail_list"] = []map[string]string{}
+		}
+	}
+	return re
+}
+
+func (s *Server) alipayClose(biz map[string]string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.alipayOrder(biz)
+	if o == nil {
+		return aliFail("40004", "Business Failed", "ACQ.TRADE_NOT_EXIST", "交易不存在")
+	}
+	if o.Status != "WAIT_BUYER_PAY" {
+		return aliFail("40004", "Business Failed", "ACQ.TRADE_STATUS_ERROR", "交易状态不合法")
+	}
+	o.Status = "TRADE_CLOSED"
+	return aliSuccess(o)
+}
+
+func (s *Server) alipayRefund(biz map[string]string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.alipayOrder(biz)
+	if o == nil {
+		return aliFail("40004", "Business Failed", "ACQ.TRADE_NOT_EXIST", "交易不存在")
+	}
+	fee, err := yuanToFen(biz["refund_amount"])
+	if err != nil || fee <= 0 {
+		return aliFail("40004", "Business Failed", "ACQ.INVALID_PARAMETER", "参数无效")
+	}
+	if o.Status != "TRADE_SUCCESS" {
+		return aliFail("40004", "Business Failed", "ACQ.TRADE_STATUS_ERROR", "交易状态不合法")
+	}
+	if o.RefundFee+fee > o.TotalFee {
+		return aliFail("40004", "Business Failed", "ACQ.REFUND_AMT_NOT_EQUAL_TOTAL", "退款金额超限")
+	}
+	o.RefundFee += fee
+	if o.RefundFee == o.TotalFee {
+		o.Status = "TRADE_CLOSED"
+	}
+	re := aliSuccess(o)
+	re["fund_change"] = "Y"
+	re["refund_fee"] = fenToYuan(o.RefundFee)
+	re["gmt_refund_pay"] = time.Now().In(chinaZone).Format("2006-01-02 15:04:05")
+	return re
+}
+
+// alipayBillOrders 在date(yyyy-MM-dd)支付的订单
+func (s *Server) alipayBillOrders(date string) []*Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var orders []*Order
+	for _, o := range s.orders {
+		if o.Provider == Alipay && !o.PaidAt.IsZero() && o.PaidAt.In(chinaZone).Format("2006-01-02") == date {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].TradeNum < orders[j].TradeNum })
+	return orders
+}
+
+func (s *Server) alipayBillURL(biz map[string]string) map[string]interface{} {
+	if biz["bill_type"] != "trade" {
+		return aliFail("40004", "Business Failed", "isp.bill_type_not_support", "账单类型不支持")
+	}
+	if len(s.alipayBillOrders(biz["bill_date"])) == 0 {
+		return aliFail("40004", "Business Failed", "isp.bill_not_exist", "账单不存在")
+	}
+	return map[string]interface{}{
+		"code":              "10000",
+		"msg":               "Success",
+		"bill_download_url": "https://dwbillcenter.alipay.com/downloadBillFile.resource?bizType=trade&fileType=csv.zip&billDate=" + biz["bill_date"],
+	}
+}
+
+// serveAlipayBill 生成GBK编码的业务明细和汇总zip, 服务费按0.6%计
+func (s *Server) serveAlipayBill(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("billDate")
+	orders := s.alipayBillOrders(date)
+	if len(orders) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	var detail, summary bytes.Buffer
+	detail.WriteString("#支付宝业务明细查询\r\n#账号：[" + s.AppID + "]\r\n")
+	detail.WriteString("#-----------------------------------------业务明细列表----------------------------------------\r\n")
+	detail.WriteString("支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户," +
+		"订单金额（元）,商家实收（元）,支付宝红包（元）,集分宝（元）,支付宝优惠（元）,商家优惠（元）,券核销金额（元）,券名称," +
+		"商家红包消费金额（元）,卡消费金额（元）,退款批次号/请求号,服务费（元）,分润（元）,备注\r\n")
+	var trades, refunds, total, fee int64
+	row := func(o *Order, kind string, amount, orderFee int64, refundNo string) {
+		t := o.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05")
+		fields := []string{o.TransactionID, o.TradeNum, kind, o.Subject, t, t, "", "", "", "", "159****5620",
+			fenToYuan(amount), fenToYuan(amount), "0.00", "0.00", "0.00", "0.00", "0.00", "", "0.00", "0.00", refundNo, fenToYuan(-orderFee), "0.00", ""}
+		detail.WriteString(strings.Join(fields, "\t,") + "\r\n")
+		total += amount
+		fee += orderFee
+	}
+	for _, o := range orders {
+		row(o, "交易", o.TotalFee, (o.TotalFee*6+500)/1000, "")
+		trades++
+		if o.RefundFee > 0 {
+			row(o, "退款", -o.RefundFee, -(o.RefundFee*6+500)/1000, o.TradeNum+"-refund")
+			refunds++
+		}
+	}
+	detail.WriteString("#-----------------------------------------业务明细列表结束------------------------------------\r\n")
+	detail.WriteString(fmt.Sprintf("#交易合计：%d笔，退款合计：%d笔\r\n", trades, refunds))
+
+	summary.WriteString("#支付宝业务汇总查询\r\n")
+	summary.WriteString("门店编号,门店名称,交易订单总笔数,退款订单总笔数,订单金额（元）,商家实收（元）,支付宝优惠（元）,商家优惠（元）," +
+		"卡消费金额（元）,服务费（元）,分润（元）,实收净额（元）\r\n")
+	summary.WriteString(fmt.Sprintf("合计,,%d,%d,%s,%s,0.00,0.00,0.00,%s,0.00,%s\r\n",
+		trades, refunds, fenToYuan(total), fenToYuan(total), fenToYuan(-fee), fenToYuan(total-fee)))
+
+	prefix := s.AppID + "_" + strings.Replace(date, "-", "", -1)
+	zw := zip.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		body []byte
+	}{{prefix + "_业务明细.csv", detail.Bytes()}, {prefix + "_业务明细(汇总).csv", summary.Bytes()}} {
+		body, err := simplifiedchinese.GBK.NewEncoder().Bytes(f.body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fw.Write(body)
+	}
+	zw.Close()
+}
+
+// alipayRespond 按请求的签名类型对应答签名, 请求加密时加密应答
+func (s *Server) alipayRespond(w http.ResponseWriter, m map[string]string, re map[string]interface{}) {
+	content, _ := json.Marshal(re)
+	if m["encrypt_type"] == "AES" {
+		encrypted, err := client.AliEncrypt(s.AlipayAESKey, string(content))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, _ = json.Marshal(encrypted)
+	}
+	sign, err := rsaSign(s.AlipayKey, m["sign_type"], string(content))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key := strings.Replace(m["method"], ".", "_", -1) + "_response"
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	if m["app_cert_sn"] != "" {
+		fmt.Fprintf(w, `{"%s":%s,"alipay_cert_sn":"%s","sign":"%s"}`, key, content, s.alipayCertSN, sign)
+		return
+	}
+	fmt.Fprintf(w, `{"%s":%s,"sign":"%s"}`, key, content, sign)
+}
+
+// alipayNotify 发送支付宝异步通知
+func (s *Server) alipayNotify(o Order) error {
+	var m = make(map[string]string)
+	m["notify_time"] = time.Now().In(chinaZone).Format("2006-01-02 15:04:05")
+	m["notify_type"] = "trade_status_sync"
+	m["notify_id"] = fmt.Sprintf("gopaytest%d", time.Now().UnixNano())
+	m["app_id"] = s.AppID
+	m["charset"] = "utf-8"
+	m["version"] = "1.0"
+	m["sign_type"] = o.SignType
+	m["trade_no"] = o.TransactionID
+	m["out_trade_no"] = o.TradeNum
+	m["trade_status"] = o.Status
+	m["total_amount"] = fenToYuan(o.TotalFee)
+	m["subject"] = o.Subject
+	if o.Attach != "" {
+		m["passback_params"] = o.Attach
+	}
+	if !o.PaidAt.IsZero() {
+		m["receipt_amount"] = fenToYuan(o.TotalFee - o.RefundFee)
+		m["buyer_pay_amount"] = fenToYuan(o.TotalFee)
+		m["gmt_payment"] = o.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05")
+		m["buyer_id"] = "2088101117955611"
+	}
+	sign, err := rsaSign(s.AlipayKey, o.SignType, signContent(m, "sign", "sign_type"))
+	if err != nil {
+		return err
+	}
+	m["sign"] = sign
+
+	var values = url.Values{}
+	for k, v := range m {
+		values.Set(k, v)
+	}
+	resp, err := http.PostForm(o.NotifyURL, values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if strings.TrimSpace(string(body)) != "success" {
+		return fmt.Errorf("gopaytest: alipay notify returned %q", body)
+	}
+	return nil
+}
+
+func aliSuccess(o *Order) map[string]interface{} {
+	return map[string]interface{}{
+		"code":         "10000",
+		"msg":          "Success",
+		"trade_no":     o.TransactionID,
+		"out_trade_no": o.TradeNum,
+	}
+}
+
+func aliFail(code, msg, subCode, subMsg string) map[string]interface{} {
+	return map[string]interface{}{"code": code, "msg": msg, "sub_code": subCode, "sub_msg": subMsg}
+}
+
+// signContent 按key排序拼接非空参数, 跳过exclude中的参数
+func signContent(m map[string]string, exclude ...string) string {
+	var data []string
+	for k, v := range m {
+		if v == "" || contains(exclude, k) {
+			continue
+		}
+		data = append(data, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(data)
+	return strings.Join(data, "&")
+}
+
+func rsaHash(signType string) (crypto.Hash, error) {
+	switch signType {
+	case "RSA":
+		return crypto.SHA1, nil
+	case "RSA2":
+		return crypto.SHA256, nil
+	}
+	return 0, errors.New("gopaytest: unknown sign_type " + signType)
+}
+
+func digest(hash crypto.Hash, data string) []byte {
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(data))
+		return sum[:]
+	}
+	sum := sha256.Sum256([]byte(data))
+	return sum[:]
+}
+
+func rsaSign(key *rsa.PrivateKey, signType, data string) (string, error) {
+	hash, err := rsaHash(signType)
+	if err != nil {
+		return "", err
+	}
+	signByte, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest(hash, data))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signByte), nil
+}
+
+func rsaVerify(key *rsa.PublicKey, signType, data, sign string) error {
+	hash, err := rsaHash(signType)
+	if err != nil {
+		return err
+	}
+	signByte, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(key, hash, digest(hash, data), signByte)
+}
+
+func formToMap(values url.Values) map[string]string {
+	m := make(map[string]string)
+	for k, v := range values {
+		m[k] = v[0]
+	}
+	return m
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func yuanToFen(s string) (int64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.New("gopaytest: invalid amount " + s)
+	}
+	return int64(math.Round(f * 100)), nil
+}
+
+func fenToYuan(fen int64) string {
+	if fen < 0 {
+		return "-" + fenToYuan(-fen)
+	}
+	return fmt.Sprintf("%d.%02d", fen/100, fen%100)
+}
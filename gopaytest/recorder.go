@@ -0,0 +1,309 @@
+package gopaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sulrex/gopay/client"
+)
+
+// Mode 录制器工作模式
+type Mode int
+
+// 录制器工作模式
+const (
+	ModeReplay Mode = iota // 从文件回放, 不访问网络
+	ModeRecord             // 转发请求并写入文件
+)
+
+// Redacted 脱敏后的字段值
+const Redacted = "REDACTED"
+
+// 请求中需要脱敏的字段: 签名, 随机串, 密钥
+var requestRedactKeys = map[string]bool{
+	"sign":      true,
+	"paySign":   true,
+	"nonce_str": true,
+	"nonceStr":  true,
+	"noncestr":  true,
+	"key":       true,
+}
+
+// 应答中需要脱敏的字段. 支付宝应答签名需在回放时验签, 不脱敏
+var responseRedactKeys = map[string]bool{
+	"nonce_str":       true,
+	"sandbox_signkey": true,
+}
+
+// 不写入文件的请求头
+var redactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Interaction 一次请求和应答
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 脱敏后的请求
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// RecordedResponse 脱敏后的应答
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Recorder 录制/回放网关请求的Transport.
+// 录制时请求和应答脱敏后保存到Path; 回放时按方法, 地址和规范化后的请求体依次匹配,
+// 配合util.SetClock和util.SetNonceSource可得到稳定的请求内容
+type Recorder struct {
+	Path string
+	Mode Mode
+	Next http.RoundTripper // 录制时实际发送请求, 为空使用http.DefaultTransport
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewRecorder 创建录制器, 回放模式下读取录制文件
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	rec := &Recorder{Path: path, Mode: mode}
+	if mode != ModeReplay {
+		return rec, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("gopaytest: read cassette: " + err.Error())
+	}
+	err = json.Unmarshal(data, &rec.interactions)
+	if err != nil {
+		return nil, errors.New("gopaytest: json.Unmarshal: " + err.Error())
+	}
+	rec.used = make([]bool, len(rec.interactions))
+	return rec, nil
+}
+
+// Wrap 替换客户端的Transport, 返回恢复函数
+func (rec *Recorder) Wrap(c *client.HTTPSClient) func() {
+	transport := c.Transport
+	if rec.Next == nil {
+		rec.Next = transport
+	}
+	c.Transport = rec
+	return func() {
+		c.Transport = transport
+	}
+}
+
+// Interactions 已录制或已加载的请求
+func (rec *Recorder) Interactions() []Interaction {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Interaction(nil), rec.interactions...)
+}
+
+// RoundTrip 实现http.RoundTripper
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	req := RecordedRequest{
+		Method: r.Method,
+		URL:    redactURL(r.URL),
+		Header: redactHeader(r.Header),
+		Body:   redactBody(body, requestRedactKeys),
+	}
+	if rec.Mode == ModeRecord {
+		return rec.record(r, req)
+	}
+	return rec.replay(r, req)
+}
+
+func (rec *Recorder) record(r *http.Request, req RecordedRequest) (*http.Response, error) {
+	next := rec.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.interactions = append(rec.interactions, Interaction{
+		Request: req,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       redactBody(body, responseRedactKeys),
+		},
+	})
+	rec.used = append(rec.used, true)
+	return resp, rec.save()
+}
+
+func (rec *Recorder) replay(r *http.Request, req RecordedRequest) (*http.Response, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for i, in := range rec.interactions {
+		if rec.used[i] || in.Request.Method != req.Method || in.Request.URL != req.URL || in.Request.Body != req.Body {
+			continue
+		}
+		rec.used[i] = true
+		header := http.Header{}
+		for k, v := range in.Response.Header {
+			header[k] = append([]string(nil), v...)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       r,
+		}, nil
+	}
+	return nil, fmt.Errorf("gopaytest: no recorded interaction for %s %s", req.Method, req.URL)
+}
+
+// save 写入录制文件, 调用方持有锁
+func (rec *Recorder) save() error {
+	data, err := json.MarshalIndent(rec.interactions, "", "  ")
+	if err != nil {
+		return errors.New("gopaytest: json.Marshal: " + err.Error())
+	}
+	err = ioutil.WriteFile(rec.Path, data, os.FileMode(0644))
+	if err != nil {
+		return errors.New("gopaytest: write cassette: " + err.Error())
+	}
+	return nil
+}
+
+func redactURL(u *url.URL) string {
+	u2 := *u
+	if u2.RawQuery != "" {
+		q := u2.Query()
+		redactValues(q, requestRedactKeys)
+		u2.RawQuery = q.Encode()
+	}
+	return u2.String()
+}
+
+func redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	h2 := h.Clone()
+	for _, k := range redactHeaders {
+		h2.Del(k)
+	}
+	return h2
+}
+
+func redactValues(v url.Values, keys map[string]bool) {
+	for k := range v {
+		if keys[k] {
+			v.Set(k, Redacted)
+		}
+	}
+}
+
+// redactBody 脱敏并规范化请求体: XML和JSON按字段名排序, 表单按url编码排序
+func redactBody(body []byte, keys map[string]bool) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	switch trimmed[0] {
+	case '<':
+		if s, ok := redactXML(trimmed, keys); ok {
+			return s
+		}
+	case '{':
+		var m map[string]json.RawMessage
+		if json.Unmarshal(trimmed, &m) == nil {
+			for k := range m {
+				if keys[k] {
+					m[k] = json.RawMessage(`"` + Redacted + `"`)
+				}
+			}
+			data, _ := json.Marshal(m)
+			return string(data)
+		}
+	default:
+		if v, err := url.ParseQuery(string(trimmed)); err == nil && strings.Contains(string(trimmed), "=") {
+			redactValues(v, keys)
+			return v.Encode()
+		}
+	}
+	return string(body)
+}
+
+// redactXML 处理微信接口的单层XML
+func redactXML(body []byte, keys map[string]bool) (string, bool) {
+	var doc struct {
+		XMLName xml.Name
+		Fields  []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if xml.Unmarshal(body, &doc) != nil {
+		return "", false
+	}
+	var m = make(map[string]string)
+	var names []string
+	for _, f := range doc.Fields {
+		v := f.Value
+		if keys[f.XMLName.Local] {
+			v = Redacted
+		}
+		m[f.XMLName.Local] = v
+		names = append(names, f.XMLName.Local)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("<" + doc.XMLName.Local + ">")
+	for _, k := range names {
+		buf.WriteString("<" + k + ">")
+		xml.EscapeText(&buf, []byte(m[k]))
+		buf.WriteString("</" + k + ">")
+	}
+	buf.WriteString("</" + doc.XMLName.Local + ">")
+	return buf.String(), true
+}
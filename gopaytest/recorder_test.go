@@ -0,0 +1,68 @@
+package gopaytest
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/util"
+)
+
+func TestRecorder(t *testing.T) {
+	util.SetClock(func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) })
+	util.SetNonceSource(util.FixedNonce("5K8264ILTKCH16CQ2502SI8ZNMTM67VS"))
+	defer util.SetClock(nil)
+	defer util.SetNonceSource(nil)
+
+	path := filepath.Join(t.TempDir(), "wechat.json")
+	charge := &common.Charge{MoneyFee: 0.01, Describe: "test pay", TradeNum: "11111111124", CallbackURL: "https://example.com/callback"}
+
+	// 录制
+	gateway := NewServer()
+	wc := gateway.WechatAppClient()
+	restore := gateway.Install()
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrap := rec.Wrap(client.HTTPSC)
+	recorded, err := wc.Pay(charge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrap()
+	restore()
+	gateway.Close()
+
+	for _, in := range rec.Interactions() {
+		if strings.Contains(in.Request.Body, "5K8264ILTKCH16CQ2502SI8ZNMTM67VS") || strings.Contains(in.Request.Body, gateway.WechatKey) {
+			t.Fatalf("request not redacted: %s", in.Request.Body)
+		}
+		if !strings.Contains(in.Request.Body, "<sign>"+Redacted+"</sign>") {
+			t.Fatalf("sign not redacted: %s", in.Request.Body)
+		}
+	}
+
+	// 回放, 网关已关闭
+	rec, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Wrap(client.HTTPSC)()
+	replayed, err := wc.Pay(charge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Fatalf("replayed %v, recorded %v", replayed, recorded)
+	}
+
+	// 未录制的请求返回错误
+	if _, err := client.HTTPSC.Post(wc.PayURL, "text/xml", strings.NewReader("<xml></xml>")); err == nil {
+		t.Fatal("replayed a request that was not recorded")
+	}
+}
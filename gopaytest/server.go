@@ -0,0 +1,296 @@
+// Package gopaytest 提供模拟支付宝和微信支付网关的测试服务, 使支付流程可以离线测试
+package gopaytest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sulrex/gopay/client"
+)
+
+// 支付渠道
+const (
+	Alipay = "alipay"
+	Wechat = "wechat"
+)
+
+// 网关返回的时间都是北京时间
+var chinaZone = time.FixedZone("CST", 8*3600)
+
+// 模拟网关接管的域名
+var gatewayHosts = map[string]bool{
+	"openapi.alipay.com":      true,
+	"openapi.alipaydev.com":   true,
+	"api.mch.weixin.qq.com":   true,
+	"apihk.mch.weixin.qq.com": true,
+	"dwbillcenter.alipay.com": true,
+	"fraud.mch.weixin.qq.com": true,
+}
+
+// Order 模拟网关中的订单
+type Order struct {
+	Provider      string // 支付渠道
+	TradeNum      string // 商户订单号
+	TransactionID string // 渠道交易号
+	TotalFee      int64  // 订单金额(最小货币单位)
+	Currency      string // 标价币种
+	RefundFee     int64  // 已退款金额(分)
+	Status        string // 渠道交易状态, 如NOTPAY/SUCCESS, WAIT_BUYER_PAY/TRADE_SUCCESS
+	Subject       string // 商品描述
+	OpenID        string // 微信用户标识
+	Attach        string // 微信attach或支付宝passback_params, 通知和查询时原样返回
+	NotifyURL     string // 异步通知地址
+	SignType      string // 支付宝签名类型
+	TradeType     string // 微信交易类型
+	Sandbox       bool   // 微信沙箱下单
+	PaidAt        time.Time
+}
+
+// Server 模拟支付宝网关(gateway.do)和微信支付(pay/*及企业付款)接口
+type Server struct {
+	*httptest.Server
+
+	AppID            string          // 支付宝及微信应用ID
+	MchID            string          // 微信商户号
+	WechatKey        string          // 微信商户密钥
+	WechatSandboxKey string          // 微信沙箱密钥
+	AlipayKey        *rsa.PrivateKey // 支付宝签名私钥, 客户端用其公钥验签
+	AppKey           *rsa.PrivateKey // 商户应用私钥, 客户端用于签名
+	AlipayAESKey     string          // 支付宝AES密钥(base64), 客户端设置AESKey时使用
+	WechatBankKey    *rsa.PrivateKey // 微信付款到银行卡的RSA私钥, 公钥通过risk/getpublickey下发
+	AliRootCert      []byte          // 支付宝根证书(PEM), 公钥证书模式使用
+	AliAppCert       []byte          // 应用公钥证书(PEM)
+	AlipayCert       []byte          // 支付宝公钥证书(PEM)
+	AliBalance       int64           // 支付宝账户可用余额(分), 转账时扣减
+	TransferFailures int             // 企业付款或转账成功后仍返回系统繁忙的次数, 用于测试重试
+
+	mu            sync.Mutex
+	orders        map[string]*Order
+	transfers     map[string]*Transfer
+	bankTransfers map[string]*Transfer
+	aliTransfers  map[string]*Transfer
+	appCertSN     string
+	alipayCertSN  string
+	rootCertSN    string
+	publicKeyReqs int
+	seq           int64
+}
+
+// NewServer 启动模拟网关, 生成测试用密钥
+func NewServer() *Server {
+	alipayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	appKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	bankKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		AppID:            "2016000000000001",
+		MchID:            "1900000001",
+		WechatKey:        "gopaytestwechatkey00000000000000",
+		WechatSandboxKey: "gopaytestsandboxkey0000000000000",
+		AlipayKey:        alipayKey,
+		AppKey:           appKey,
+		AlipayAESKey:     "aa4BtZ4tspm2wnXLb1ThQA==",
+		WechatBankKey:    bankKey,
+		orders:           make(map[string]*Order),
+		transfers:        make(map[string]*Transfer),
+		bankTransfers:    make(map[string]*Transfer),
+		aliTransfers:     make(map[string]*Transfer),
+		AliBalance:       100000000,
+	}
+	if err := s.initAliCerts(); err != nil {
+		panic(err)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Install 将client.HTTPSC发往支付宝和微信网关的请求转到模拟服务, 返回恢复函数
+func (s *Server) Install() func() {
+	transport := client.HTTPSC.Transport
+	client.HTTPSC.Transport = s.Transport(transport)
+	return func() {
+		client.HTTPSC.Transport = transport
+	}
+}
+
+// Transport 返回把网关请求转到模拟服务的RoundTripper, 其他请求交给next
+func (s *Server) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	target, _ := url.Parse(s.URL)
+	return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if !gatewayHosts[r.URL.Host] {
+			return next.RoundTrip(r)
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Scheme = target.Scheme
+		r2.URL.Host = target.Host
+		r2.Host = target.Host
+		return http.DefaultTransport.RoundTrip(r2)
+	})
+}
+
+// AliAppClient 返回指向模拟服务的支付宝app客户端
+func (s *Server) AliAppClient() *client.AliAppClient {
+	return &client.AliAppClient{
+		AppID:      s.AppID,
+		PrivateKey: s.AppKey,
+		PublicKey:  &s.AlipayKey.PublicKey,
+	}
+}
+
+// AliWebClient 返回指向模拟服务的支付宝网页客户端
+func (s *Server) AliWebClient() *client.AliWebClient {
+	return &client.AliWebClient{
+		AppID:      s.AppID,
+		PrivateKey: s.AppKey,
+		PublicKey:  &s.AlipayKey.PublicKey,
+	}
+}
+
+// WechatWebClient 返回指向模拟服务的微信公众号客户端
+func (s *Server) WechatWebClient() *client.WechatWebClient {
+	return &client.WechatWebClient{
+		AppID:      s.AppID,
+		MchID:      s.MchID,
+		Key:        s.WechatKey,
+		PayURL:     "https://api.mch.weixin.qq.com/pay/unifiedorder",
+		CertClient: s.CertClient(),
+	}
+}
+
+// WechatAppClient 返回指向模拟服务的微信app客户端
+func (s *Server) WechatAppClient() *client.WechatAppClient {
+	return &client.WechatAppClient{
+		AppID:      s.AppID,
+		MchID:      s.MchID,
+		Key:        s.WechatKey,
+		PayURL:     "https://api.mch.weixin.qq.com/pay/unifiedorder",
+		CertClient: s.CertClient(),
+	}
+}
+
+// WechatMiniProgramClient 返回指向模拟服务的微信小程序客户端
+func (s *Server) WechatMiniProgramClient() *client.WechatMiniProgramClient {
+	return &client.WechatMiniProgramClient{
+		AppID:      s.AppID,
+		MchID:      s.MchID,
+		Key:        s.WechatKey,
+		PayURL:     "https://api.mch.weixin.qq.com/pay/unifiedorder",
+		CertClient: s.CertClient(),
+	}
+}
+
+// CertClient 返回请求模拟服务的证书客户端, 模拟服务不校验客户端证书
+func (s *Server) CertClient() *client.HTTPSClient {
+	return &client.HTTPSClient{Client: http.Client{Transport: s.Transport(nil)}}
+}
+
+// Order 按商户订单号获取订单副本
+func (s *Server) Order(tradeNum string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[tradeNum]
+	if !ok {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// PayOrder 模拟用户付款成功
+func (s *Server) PayOrder(tradeNum string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[tradeNum]
+	if !ok {
+		return errors.New("gopaytest: order not found " + tradeNum)
+	}
+	switch o.Status {
+	case "NOTPAY", "WAIT_BUYER_PAY":
+	default:
+		return fmt.Errorf("gopaytest: order %s is %s", tradeNum, o.Status)
+	}
+	if o.Provider == Alipay {
+		o.Status = "TRADE_SUCCESS"
+	} else {
+		o.Status = "SUCCESS"
+		if o.OpenID == "" {
+			// APP支付下单时没有openid, 付款后才知道付款人
+			o.OpenID = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+		}
+	}
+	o.PaidAt = time.Now()
+	return nil
+}
+
+// Notify 向订单的异步通知地址发送签名的支付结果通知, 商户未返回成功时返回错误
+func (s *Server) Notify(tradeNum string) error {
+	o, ok := s.Order(tradeNum)
+	if !ok {
+		return errors.New("gopaytest: order not found " + tradeNum)
+	}
+	if o.NotifyURL == "" {
+		return errors.New("gopaytest: order has no notify url " + tradeNum)
+	}
+	if o.Provider == Alipay {
+		return s.alipayNotify(o)
+	}
+	return s.wechatNotify(o)
+}
+
+// addOrder 登记订单, 未支付的同号订单被覆盖, 已支付时返回错误
+func (s *Server) addOrder(o *Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.orders[o.TradeNum]; ok {
+		if old.Status != "NOTPAY" && old.Status != "WAIT_BUYER_PAY" {
+			return errors.New("gopaytest: order paid " + o.TradeNum)
+		}
+		o.TransactionID = old.TransactionID
+	} else {
+		s.seq++
+		o.TransactionID = fmt.Sprintf("4200%s%016d", time.Now().Format("20060102"), s.seq)
+	}
+	s.orders[o.TradeNum] = o
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/sandboxnew")
+	switch {
+	case path == "/gateway.do":
+		s.serveAlipay(w, r)
+	case path == "/downloadBillFile.resource":
+		s.serveAlipayBill(w, r)
+	case path == "/pay/getsignkey":
+		s.serveWechatSignKey(w, r)
+	case strings.HasPrefix(path, "/pay/") || strings.HasPrefix(path, "/secapi/pay/") || strings.HasPrefix(path, "/mmpaymkttransfers/") ||
+		strings.HasPrefix(path, "/mmpaysptrans/") || path == "/risk/getpublickey":
+		s.serveWechat(w, r, path, path != r.URL.Path)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
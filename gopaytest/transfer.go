@@ -0,0 +1,286 @@
+package gopaytest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/constant"
+	"github.com/sulrex/gopay/util"
+)
+
+// Transfer 模拟网关中的企业付款或支付宝转账
+type Transfer struct {
+	TradeNum  string // 商户付款单号
+	PaymentNo string // 渠道付款单号
+	OpenID    string
+	Payee     string // 支付宝收款方标识, 仅支付宝转账
+	Amount    int64  // 付款金额(分)
+	Desc      string
+	UserName  string // 校验的收款用户姓名, 付款到银行卡时为解密后的收款方用户名
+	BankNo    string // 解密后的银行卡号, 仅付款到银行卡
+	BankCode  string // 开户行编码, 仅付款到银行卡
+	Fee       int64  // 手续费(分), 仅付款到银行卡
+	PaidAt    time.Time
+}
+
+// WechatTransferClient 返回指向模拟服务的微信企业付款客户端, 重试不等待
+func (s *Server) WechatTransferClient() *client.WechatTransferClient {
+	return &client.WechatTransferClient{
+		AppID:         s.AppID,
+		MchID:         s.MchID,
+		Key:           s.WechatKey,
+		CertClient:    s.CertClient(),
+		RetryInterval: time.Millisecond,
+	}
+}
+
+// Transfer 按商户付款单号获取付款副本
+func (s *Server) Transfer(tradeNum string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[tradeNum]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// BankTransfer 按商户付款单号获取付款到银行卡的副本
+func (s *Server) BankTransfer(tradeNum string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.bankTransfers[tradeNum]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// PublicKeyRequests risk/getpublickey被调用的次数, 用于确认客户端缓存了公钥
+func (s *Server) PublicKeyRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.publicKeyReqs
+}
+
+// TransferCount 已成功的企业付款笔数, 用于确认重试没有重复付款
+func (s *Server) TransferCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.transfers)
+}
+
+// serveWechatTransfer 企业付款接口, 应答不带签名
+func (s *Server) serveWechatTransfer(w http.ResponseWriter, path string, m map[string]string) {
+	var re map[string]string
+	switch path {
+	case "/mmpaymkttransfers/promotion/transfers":
+		re = s.wechatTransfer(m)
+		re["mch_appid"] = m["mch_appid"]
+		re["mchid"] = m["mchid"]
+	case "/mmpaymkttransfers/gettransferinfo":
+		re = s.wechatTransferInfo(m)
+		re["appid"] = m["appid"]
+		re["mch_id"] = m["mch_id"]
+	case "/mmpaysptrans/pay_bank":
+		re = s.wechatTransferBank(m)
+		re["mch_id"] = m["mch_id"]
+	case "/mmpaysptrans/query_bank":
+		re = s.wechatQueryBank(m)
+		re["mch_id"] = m["mch_id"]
+	case "/risk/getpublickey":
+		re = s.wechatPublicKey()
+		re["mch_id"] = m["mch_id"]
+	}
+	re["return_code"] = "SUCCESS"
+	re["return_msg"] = "OK"
+	re["nonce_str"] = util.RandomStr()
+	s.wechatRespond(w, "", re)
+}
+
+// wechatTransfer 同一partner_trade_no只付款一次, 重复请求返回原结果;
+// TransferFailures大于0时付款后仍返回SYSTEMERROR, 模拟结果未知
+func (s *Server) wechatTransfer(m map[string]string) map[string]string {
+	amount, err := strconv.ParseInt(m["amount"], 10, 64)
+	if err != nil || amount <= 0 || m["partner_trade_no"] == "" || m["openid"] == "" || m["desc"] == "" {
+		return wechatFail("PARAM_ERROR", "参数错误")
+	}
+	if m["mch_appid"] != s.AppID || m["mchid"] != s.MchID {
+		return wechatFail("MCHID_APPID_MISMATCH", "商户号和appid没有绑定关系")
+	}
+	switch m["check_name"] {
+	case "NO_CHECK":
+	case "FORCE_CHECK":
+		if m["re_user_name"] == "" {
+			return wechatFail("PARAM_ERROR", "校验姓名时必须填写收款用户姓名")
+		}
+	default:
+		return wechatFail("PARAM_ERROR", "check_name参数错误")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[m["partner_trade_no"]]
+	if ok && (t.OpenID != m["openid"] || t.Amount != amount || t.UserName != m["re_user_name"]) {
+		return wechatFail("PARAM_ERROR", "商户订单号重复且参数不一致")
+	}
+	if !ok {
+		s.seq++
+		t = &Transfer{
+			TradeNum:  m["partner_trade_no"],
+			PaymentNo: fmt.Sprintf("1000%s%016d", time.Now().Format("20060102"), s.seq),
+			OpenID:    m["openid"],
+			Amount:    amount,
+			Desc:      m["desc"],
+			UserName:  m["re_user_name"],
+			PaidAt:    time.Now(),
+		}
+		s.transfers[t.TradeNum] = t
+	}
+	if s.TransferFailures > 0 {
+		s.TransferFailures--
+		return wechatFail("SYSTEMERROR", "系统繁忙,请稍后再试")
+	}
+	return map[string]string{
+		"result_code":      "SUCCESS",
+		"partner_trade_no": t.TradeNum,
+		"payment_no":       t.PaymentNo,
+		"payment_time":     t.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05"),
+	}
+}
+
+func (s *Server) wechatTransferInfo(m map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[m["partner_trade_no"]]
+	if !ok {
+		return wechatFail("NOT_FOUND", "指定单号数据不存在")
+	}
+	paidAt := t.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05")
+	return map[string]string{
+		"result_code":      "SUCCESS",
+		"partner_trade_no": t.TradeNum,
+		"detail_id":        t.PaymentNo,
+		"status":           "SUCCESS",
+		"openid":           t.OpenID,
+		"transfer_name":    t.UserName,
+		"payment_amount":   strconv.FormatInt(t.Amount, 10),
+		"transfer_time":    paidAt,
+		"payment_time":     paidAt,
+		"desc":             t.Desc,
+	}
+}
+
+// wechatPublicKey 下发PKCS#1格式的RSA公钥
+func (s *Server) wechatPublicKey() map[string]string {
+	s.mu.Lock()
+	s.publicKeyReqs++
+	s.mu.Unlock()
+	der := x509.MarshalPKCS1PublicKey(&s.WechatBankKey.PublicKey)
+	return map[string]string{
+		"result_code": "SUCCESS",
+		"pub_key":     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: der})),
+	}
+}
+
+// wechatTransferBank 解密卡号和姓名后受理付款, 同一partner_trade_no只受理一次, 手续费按0.1%计, 最低1元最高25元
+func (s *Server) wechatTransferBank(m map[string]string) map[string]string {
+	amount, err := strconv.ParseInt(m["amount"], 10, 64)
+	if err != nil || amount <= 0 || m["partner_trade_no"] == "" {
+		return wechatFail("PARAM_ERROR", "参数错误")
+	}
+	if _, ok := constant.BankNames[m["bank_code"]]; !ok {
+		return wechatFail("PARAM_ERROR", "不支持的开户行")
+	}
+	bankNo, err := s.wechatDecrypt(m["enc_bank_no"])
+	if err != nil {
+		return wechatFail("ENCRYPT_ERROR", "银行卡号解密失败")
+	}
+	trueName, err := s.wechatDecrypt(m["enc_true_name"])
+	if err != nil {
+		return wechatFail("ENCRYPT_ERROR", "收款方用户名解密失败")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.bankTransfers[m["partner_trade_no"]]
+	if ok && (t.BankNo != bankNo || t.UserName != trueName || t.Amount != amount) {
+		return wechatFail("PARAM_ERROR", "商户订单号重复且参数不一致")
+	}
+	if !ok {
+		fee := amount / 1000
+		if fee < 100 {
+			fee = 100
+		} else if fee > 2500 {
+			fee = 2500
+		}
+		s.seq++
+		t = &Transfer{
+			TradeNum:  m["partner_trade_no"],
+			PaymentNo: fmt.Sprintf("1001%s%016d", time.Now().Format("20060102"), s.seq),
+			Amount:    amount,
+			Desc:      m["desc"],
+			UserName:  trueName,
+			BankNo:    bankNo,
+			BankCode:  m["bank_code"],
+			Fee:       fee,
+			PaidAt:    time.Now(),
+		}
+		s.bankTransfers[t.TradeNum] = t
+	}
+	if s.TransferFailures > 0 {
+		s.TransferFailures--
+		return wechatFail("SYSTEMERROR", "系统繁忙,请稍后再试")
+	}
+	return map[string]string{
+		"result_code":      "SUCCESS",
+		"partner_trade_no": t.TradeNum,
+		"amount":           strconv.FormatInt(t.Amount, 10),
+		"payment_no":       t.PaymentNo,
+		"cmms_amt":         strconv.FormatInt(t.Fee, 10),
+	}
+}
+
+func (s *Server) wechatQueryBank(m map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.bankTransfers[m["partner_trade_no"]]
+	if !ok {
+		return wechatFail("ORDERNOTEXIST", "订单不存在")
+	}
+	return map[string]string{
+		"result_code":      "SUCCESS",
+		"partner_trade_no": t.TradeNum,
+		"payment_no":       t.PaymentNo,
+		"bank_no_md5":      fmt.Sprintf("%x", md5.Sum([]byte(t.BankNo))),
+		"true_name_md5":    fmt.Sprintf("%x", md5.Sum([]byte(t.UserName))),
+		"amount":           strconv.FormatInt(t.Amount, 10),
+		"status":           "SUCCESS",
+		"cmms_amt":         strconv.FormatInt(t.Fee, 10),
+		"create_time":      t.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05"),
+		"pay_succ_time":    t.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05"),
+	}
+}
+
+// wechatDecrypt 用WechatBankKey解密RSA-OAEP(SHA-1)加密的字段
+func (s *Server) wechatDecrypt(enc string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, s.WechatBankKey, b, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
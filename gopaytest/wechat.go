@@ -0,0 +1,385 @@
+package gopaytest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/util"
+)
+
+func (s *Server) serveWechat(w http.ResponseWriter, r *http.Request, path string, sandbox bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m := util.XmlToMap(body)
+	key := s.WechatKey
+	if sandbox {
+		key = s.WechatSandboxKey
+	}
+	genSign := client.WechatGenSign
+	if m["sign_type"] == "HMAC-SHA256" {
+		genSign = client.WechatGenHMACSign
+	}
+	sign, err := genSign(key, m)
+	if err != nil || sign != m["sign"] {
+		s.wechatRespond(w, key, map[string]string{"return_code": "FAIL", "return_msg": "签名错误"})
+		return
+	}
+
+	switch path {
+	case "/pay/downloadbill":
+		s.wechatDownloadBill(w, m)
+		return
+	case "/pay/downloadfundflow":
+		if m["sign_type"] != "HMAC-SHA256" {
+			w.Write(wechatXML(map[string]string{"return_code": "FAIL", "return_msg": "only support HMAC-SHA256", "error_code": "20003"}))
+			return
+		}
+		s.wechatDownloadFundFlow(w, m)
+		return
+	case "/mmpaymkttransfers/promotion/transfers", "/mmpaymkttransfers/gettransferinfo",
+		"/mmpaysptrans/pay_bank", "/mmpaysptrans/query_bank", "/risk/getpublickey":
+		s.serveWechatTransfer(w, path, m)
+		return
+	}
+
+	var re map[string]string
+	switch path {
+	case "/pay/unifiedorder":
+		re = s.wechatUnifiedOrder(m, sandbox)
+	case "/pay/orderquery":
+		re = s.wechatQuery(m)
+	case "/pay/closeorder":
+		re = s.wechatClose(m)
+	case "/secapi/pay/refund":
+		re = s.wechatRefund(m)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	re["return_code"] = "SUCCESS"
+	re["return_msg"] = "OK"
+	re["appid"] = m["appid"]
+	re["mch_id"] = m["mch_id"]
+	re["nonce_str"] = util.RandomStr()
+	s.wechatRespond(w, key, re)
+}
+
+// serveWechatSignKey 沙箱获取签名密钥
+func (s *Server) serveWechatSignKey(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m := util.XmlToMap(body)
+	sign, err := client.WechatGenSign(s.WechatKey, m)
+	if err != nil || sign != m["sign"] || m["mch_id"] != s.MchID {
+		s.wechatRespond(w, s.WechatKey, map[string]string{"return_code": "FAIL", "return_msg": "签名错误"})
+		return
+	}
+	s.wechatRespond(w, "", map[string]string{
+		"return_code":     "SUCCESS",
+		"return_msg":      "ok",
+		"mch_id":          s.MchID,
+		"sandbox_signkey": s.WechatSandboxKey,
+	})
+}
+
+func (s *Server) wechatUnifiedOrder(m map[string]string, sandbox bool) map[string]string {
+	total, err := strconv.ParseInt(m["total_fee"], 10, 64)
+	if err != nil || total <= 0 || m["out_trade_no"] == "" {
+		return wechatFail("PARAM_ERROR", "参数错误")
+	}
+	if m["trade_type"] == "JSAPI" && m["openid"] == "" {
+		return wechatFail("PARAM_ERROR", "JSAPI支付必须传openid")
+	}
+	currency := m["fee_type"]
+	if currency == "" {
+		currency = "CNY"
+	}
+	o := &Order{
+		Provider:  Wechat,
+		TradeNum:  m["out_trade_no"],
+		TotalFee:  total,
+		Status:    "NOTPAY",
+		Subject:   m["body"],
+		OpenID:    m["openid"],
+		Attach:    m["attach"],
+		Currency:  currency,
+		NotifyURL: m["notify_url"],
+		TradeType: m["trade_type"],
+		Sandbox:   sandbox,
+	}
+	if err := s.addOrder(o); err != nil {
+		return wechatFail("ORDERPAID", "该订单已支付")
+	}
+	re := map[string]string{
+		"result_code": "SUCCESS",
+		"trade_type":  o.TradeType,
+		"prepay_id":   "wx" + o.TransactionID,
+	}
+	switch o.TradeType {
+	case "NATIVE":
+		re["code_url"] = "weixin://wxpay/bizpayurl?pr=" + o.TransactionID
+	case "MWEB":
+		re["mweb_url"] = "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx" + o.TransactionID
+	}
+	return re
+}
+
+// wechatOrder 按out_trade_no或transaction_id查找订单, 调用方需持有锁
+func (s *Server) wechatOrder(m map[string]string) *Order {
+	if o, ok := s.orders[m["out_trade_no"]]; ok && o.Provider == Wechat {
+		return o
+	}
+	for _, o := range s.orders {
+		if o.Provider == Wechat && m["transaction_id"] != "" && o.TransactionID == m["transaction_id"] {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) wechatQuery(m map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.wechatOrder(m)
+	if o == nil {
+		return wechatFail("ORDERNOTEXIST", "订单不存在")
+	}
+	re := wechatOrderFields(o)
+	re["result_code"] = "SUCCESS"
+	re["trade_state"] = o.Status
+	re["trade_state_desc"] = o.Status
+	return re
+}
+
+func (s *Server) wechatClose(m map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.wechatOrder(m)
+	if o == nil {
+		return wechatFail("ORDERNOTEXIST", "订单不存在")
+	}
+	switch o.Status {
+	case "NOTPAY":
+		o.Status = "CLOSED"
+		return map[string]string{"result_code": "SUCCESS"}
+	case "CLOSED":
+		return wechatFail("ORDERCLOSED", "订单已关闭")
+	}
+	return wechatFail("ORDERPAID", "订单已支付")
+}
+
+func (s *Server) wechatRefund(m map[string]string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.wechatOrder(m)
+	if o == nil {
+		return wechatFail("ORDERNOTEXIST", "订单不存在")
+	}
+	fee, err := strconv.ParseInt(m["refund_fee"], 10, 64)
+	if err != nil || fee <= 0 || m["out_refund_no"] == "" {
+		return wechatFail("PARAM_ERROR", "参数错误")
+	}
+	if o.Status != "SUCCESS" && o.Status != "REFUND" {
+		return wechatFail("TRADE_STATE_ERROR", "订单状态错误")
+	}
+	if o.RefundFee+fee > o.TotalFee {
+		return wechatFail("NOTENOUGH", "退款金额超限")
+	}
+	o.RefundFee += fee
+	o.Status = "REFUND"
+	re := wechatOrderFields(o)
+	re["result_code"] = "SUCCESS"
+	re["out_refund_no"] = m["out_refund_no"]
+	re["refund_id"] = "50" + o.TransactionID
+	re["refund_fee"] = strconv.FormatInt(fee, 10)
+	return re
+}
+
+// wechatBillHeader 全部订单(ALL)账单的表头
+var wechatBillHeader = "交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类," +
+	"应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注"
+
+// wechatDownloadBill 按bill_date生成已支付订单的对账单, 手续费按0.6%计
+func (s *Server) wechatDownloadBill(w http.ResponseWriter, m map[string]string) {
+	s.mu.Lock()
+	var orders []*Order
+	for _, o := range s.orders {
+		if o.Provider != Wechat || o.PaidAt.IsZero() || o.PaidAt.In(chinaZone).Format("20060102") != m["bill_date"] {
+			continue
+		}
+		if (m["bill_type"] == "SUCCESS" && o.Status != "SUCCESS") || (m["bill_type"] == "REFUND" && o.Status != "REFUND") {
+			continue
+		}
+		orders = append(orders, o)
+	}
+	s.mu.Unlock()
+	if len(orders) == 0 {
+		w.Write(wechatXML(map[string]string{"return_code": "FAIL", "return_msg": "No Bill Exist", "error_code": "20002"}))
+		return
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].TradeNum < orders[j].TradeNum })
+
+	yuan := func(fen int64) string { return fmt.Sprintf("%d.%02d", fen/100, fen%100) }
+	var buf bytes.Buffer
+	buf.WriteString(wechatBillHeader + "\r\n")
+	var total, refund, fee int64
+	for _, o := range orders {
+		orderFee := o.TotalFee * 6 // 0.6%, 单位为分的千分之一
+		fields := []string{
+			o.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05"), s.AppID, s.MchID, "0", "", o.TransactionID, o.TradeNum, o.OpenID,
+			o.TradeType, o.Status, "CMC", o.Currency, yuan(o.TotalFee), "0.00", "0", "0", yuan(o.RefundFee), "0.00", "", "",
+			o.Subject, o.Attach, fmt.Sprintf("%d.%05d", orderFee/100000, orderFee%100000), "0.60%", yuan(o.TotalFee), yuan(o.RefundFee), "",
+		}
+		buf.WriteString("`" + strings.Join(fields, ",`") + "\r\n")
+		total += o.TotalFee
+		refund += o.RefundFee
+		fee += (orderFee + 500) / 1000
+	}
+	buf.WriteString("总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n")
+	buf.WriteString(fmt.Sprintf("`%d,`%s,`%s,`0.00,`%s,`%s,`%s\r\n", len(orders), yuan(total), yuan(refund), yuan(fee), yuan(total), yuan(refund)))
+
+	if m["tar_type"] != "GZIP" {
+		w.Write(buf.Bytes())
+		return
+	}
+	gz := gzip.NewWriter(w)
+	gz.Write(buf.Bytes())
+	gz.Close()
+}
+
+// wechatDownloadFundFlow 按bill_date生成基本账户的资金账单: 每笔支付一条收入, 每笔退款一条支出
+func (s *Server) wechatDownloadFundFlow(w http.ResponseWriter, m map[string]string) {
+	s.mu.Lock()
+	var orders []*Order
+	for _, o := range s.orders {
+		if o.Provider == Wechat && !o.PaidAt.IsZero() && o.PaidAt.In(chinaZone).Format("20060102") == m["bill_date"] {
+			orders = append(orders, o)
+		}
+	}
+	s.mu.Unlock()
+	if m["account_type"] != "Basic" || len(orders) == 0 {
+		w.Write(wechatXML(map[string]string{"return_code": "FAIL", "return_msg": "No Bill Exist", "error_code": "20002"}))
+		return
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].TradeNum < orders[j].TradeNum })
+
+	yuan := func(fen int64) string { return fmt.Sprintf("%d.%02d", fen/100, fen%100) }
+	var buf bytes.Buffer
+	buf.WriteString("记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\r\n")
+	var balance, income, expense, incomeCount, expenseCount int64
+	for i, o := range orders {
+		t := o.PaidAt.In(chinaZone).Format("2006-01-02 15:04:05")
+		balance += o.TotalFee
+		income += o.TotalFee
+		incomeCount++
+		buf.WriteString(fmt.Sprintf("`%s,`%s,`%d,`交易,`交易,`收入,`%s,`%s,`system,`,`%s\r\n", t, o.TransactionID, 1000+i, yuan(o.TotalFee), yuan(balance), o.TradeNum))
+		if o.RefundFee > 0 {
+			balance -= o.RefundFee
+			expense += o.RefundFee
+			expenseCount++
+			buf.WriteString(fmt.Sprintf("`%s,`%s,`%d,`退款,`退款,`支出,`%s,`%s,`%s,`,`%s\r\n", t, o.TransactionID, 2000+i, yuan(o.RefundFee), yuan(balance), s.MchID, o.TradeNum))
+		}
+	}
+	buf.WriteString("资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\r\n")
+	buf.WriteString(fmt.Sprintf("`%d,`%d,`%s,`%d,`%s\r\n", incomeCount+expenseCount, incomeCount, yuan(income), expenseCount, yuan(expense)))
+
+	gz := gzip.NewWriter(w)
+	gz.Write(buf.Bytes())
+	gz.Close()
+}
+
+// wechatNotify 发送微信支付结果通知
+func (s *Server) wechatNotify(o Order) error {
+	m := wechatOrderFields(&o)
+	m["return_code"] = "SUCCESS"
+	m["result_code"] = "SUCCESS"
+	m["appid"] = s.AppID
+	m["mch_id"] = s.MchID
+	m["nonce_str"] = util.RandomStr()
+	m["is_subscribe"] = "N"
+	m["bank_type"] = "CMC"
+	key := s.WechatKey
+	if o.Sandbox {
+		key = s.WechatSandboxKey
+	}
+	sign, err := client.WechatGenSign(key, m)
+	if err != nil {
+		return err
+	}
+	m["sign"] = sign
+
+	resp, err := http.Post(o.NotifyURL, "text/xml;charset=UTF-8", bytes.NewReader(wechatXML(m)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "<return_code><![CDATA[SUCCESS]]></return_code>") {
+		return fmt.Errorf("gopaytest: wechat notify returned %q", body)
+	}
+	return nil
+}
+
+func (s *Server) wechatRespond(w http.ResponseWriter, key string, m map[string]string) {
+	if key != "" {
+		sign, err := client.WechatGenSign(key, m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		m["sign"] = sign
+	}
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.Write(wechatXML(m))
+}
+
+func wechatOrderFields(o *Order) map[string]string {
+	m := map[string]string{
+		"out_trade_no":   o.TradeNum,
+		"transaction_id": o.TransactionID,
+		"trade_type":     o.TradeType,
+		"openid":         o.OpenID,
+		"fee_type":       o.Currency,
+		"total_fee":      strconv.FormatInt(o.TotalFee, 10),
+	}
+	if o.Attach != "" {
+		m["attach"] = o.Attach
+	}
+	if !o.PaidAt.IsZero() {
+		m["cash_fee"] = m["total_fee"]
+		m["time_end"] = o.PaidAt.In(chinaZone).Format("20060102150405")
+	}
+	return m
+}
+
+func wechatFail(code, des string) map[string]string {
+	return map[string]string{"result_code": "FAIL", "err_code": code, "err_code_des": des}
+}
+
+func wechatXML(m map[string]string) []byte {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.WriteString("<xml>")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "<%s><![CDATA[%s]]></%s>", k, m[k], k)
+	}
+	buf.WriteString("</xml>")
+	return buf.Bytes()
+}
@@ -1,12 +1,9 @@
 package gopay
 
 import (
-	"errors"
 	"log"
 
-	"github.com/sulrex/gopay/client"
 	"github.com/sulrex/gopay/common"
-	"github.com/sulrex/gopay/constant"
 )
 
 // Pay 获取支付接口
@@ -17,7 +14,11 @@ func Pay(charge *common.Charge) (map[string]string, error) {
 		return nil, err
 	}
 
-	ct := getPayClient(charge.PayMethod)
+	ct, err := getPayClient(charge.PayMethod)
+	if err != nil {
+		log.Println("支付失败:", err, charge)
+		return nil, err
+	}
 	re, err := ct.Pay(charge)
 	if err != nil {
 		log.Println("支付失败:", err, charge)
@@ -28,29 +29,5 @@ func Pay(charge *common.Charge) (map[string]string, error) {
 
 // 验证内容
 func checkCharge(charge *common.Charge) error {
-	if charge.PayMethod < 0 {
-		return errors.New("payMethod less than 0")
-	}
-	if charge.MoneyFee < 0 {
-		return errors.New("totalFee less than 0")
-	}
-	return nil
-}
-
-// getPayClient 得到需要支付的客户端
-func getPayClient(payMethod int64) common.PayClient {
-	//如果使用余额支付
-	switch payMethod {
-	case constant.ALI_WEB:
-		return client.DefaultAliWebClient()
-	case constant.ALI_APP:
-		return client.DefaultAliAppClient()
-	case constant.WECHAT_WEB:
-		return client.DefaultWechatWebClient()
-	case constant.WECHAT_APP:
-		return client.DefaultWechatAppClient()
-	case constant.WECHAT_MINI_PROGRAM:
-		return client.DefaultWechatMiniProgramClient()
-	}
-	return nil
+	return Validate(charge)
 }
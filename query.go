@@ -0,0 +1,26 @@
+package gopay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// Query 按支付方式查询订单, 返回统一的查询结果
+func Query(ctx context.Context, payMethod int64, tradeNum string) (*common.QueryResult, error) {
+	ct, err := getPayClient(payMethod)
+	if err != nil {
+		return nil, err
+	}
+	qc, ok := ct.(common.QueryClient)
+	if !ok {
+		return nil, fmt.Errorf("%w: pay method %d does not support query", ErrUnsupportedPayMethod, payMethod)
+	}
+	re, err := qc.Query(ctx, tradeNum)
+	if err != nil {
+		return nil, err
+	}
+	re.PayMethod = payMethod
+	return re, nil
+}
@@ -0,0 +1,141 @@
+// Package reconcile 对账: 比对微信、支付宝账单记录和商户自己的订单、退款, 输出差异和手续费合计
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// Kind 记录类型
+type Kind string
+
+// 记录类型
+const (
+	Payment Kind = "PAYMENT" // 支付
+	Refund  Kind = "REFUND"  // 退款
+)
+
+// Entry 商户系统中的一笔支付或退款
+type Entry struct {
+	Kind        Kind
+	TradeNum    string // 商户订单号
+	OutRefundNo string // 商户退款单号, 仅退款
+	Amount      int64  // 支付为订单金额, 退款为退款金额, 最小货币单位(分)
+	Settled     bool   // 已支付或已退款成功
+	Status      string // 商户系统中的原始状态, 仅用于报告
+}
+
+// Ledger 商户账本
+type Ledger interface {
+	// Lookup 按账单记录查找商户的支付或退款, 不存在时返回nil, nil
+	Lookup(ctx context.Context, kind Kind, tradeNum, outRefundNo string) (*Entry, error)
+	// Settled 遍历[start, end)内支付或退款成功的记录, 用于找出账单中缺失的记录
+	Settled(ctx context.Context, start, end time.Time, fn func(*Entry) error) error
+}
+
+// Records 渠道账单记录流, client.BillIterator满足该接口
+type Records interface {
+	Next() bool
+	Record() *common.BillRecord
+	Err() error
+}
+
+// 渠道账单带汇总时, 报告中附带汇总供核对
+type summarizer interface {
+	Summary() *common.BillSummary
+}
+
+var chinaZone = time.FixedZone("CST", 8*3600)
+
+// BillPeriod 账单日date(取其年月日)对应的北京时间[start, end)
+func BillPeriod(date time.Time) (start, end time.Time) {
+	start = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, chinaZone)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Reconcile 逐条比对账单记录和商户账本, 再找出[start, end)内商户成功但账单中没有的记录
+func Reconcile(ctx context.Context, records Records, ledger Ledger, start, end time.Time) (*Report, error) {
+	if records == nil || ledger == nil {
+		return nil, errors.New("reconcile: records and ledger are required")
+	}
+	report := &Report{Start: start, End: end, Differences: []Difference{}}
+	seen := make(map[string]bool)
+	for records.Next() {
+		r := records.Record()
+		kind, amount, settled := billEntry(r)
+		report.addFees(kind, settled, r)
+
+		e, err := ledger.Lookup(ctx, kind, r.TradeNum, r.OutRefundNo)
+		if err != nil {
+			return nil, errors.New("reconcile: ledger lookup " + r.TradeNum + ": " + err.Error())
+		}
+		if e == nil {
+			report.add(Difference{Type: MissingInLedger, Kind: kind, TradeNum: r.TradeNum, OutRefundNo: r.OutRefundNo,
+				TransactionID: r.TransactionID, BillAmount: amount, BillStatus: billStatus(r)})
+			continue
+		}
+		seen[entryKey(e.Kind, e.TradeNum, e.OutRefundNo)] = true
+
+		d := Difference{Kind: kind, TradeNum: r.TradeNum, OutRefundNo: r.OutRefundNo, TransactionID: r.TransactionID,
+			BillAmount: amount, LedgerAmount: e.Amount, BillStatus: billStatus(r), LedgerStatus: e.Status}
+		matched := true
+		if settled != e.Settled {
+			d.Type = StatusMismatch
+			report.add(d)
+			matched = false
+		}
+		if amount != e.Amount {
+			d.Type = AmountMismatch
+			report.add(d)
+			matched = false
+		}
+		if matched {
+			report.Matched++
+		}
+	}
+	if err := records.Err(); err != nil {
+		return nil, errors.New("reconcile: read bill: " + err.Error())
+	}
+	if s, ok := records.(summarizer); ok {
+		report.Fees.Summary = s.Summary()
+	}
+
+	err := ledger.Settled(ctx, start, end, func(e *Entry) error {
+		if seen[entryKey(e.Kind, e.TradeNum, e.OutRefundNo)] {
+			return nil
+		}
+		report.add(Difference{Type: MissingInBill, Kind: e.Kind, TradeNum: e.TradeNum, OutRefundNo: e.OutRefundNo,
+			LedgerAmount: e.Amount, LedgerStatus: e.Status})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("reconcile: ledger settled: " + err.Error())
+	}
+	return report, nil
+}
+
+// billEntry 账单记录的类型、金额和是否成功. 微信和支付宝账单中退款记录的状态均为REFUND
+func billEntry(r *common.BillRecord) (kind Kind, amount int64, settled bool) {
+	if r.TradeState == "REFUND" {
+		return Refund, r.RefundFee, r.RefundStatus == "" || r.RefundStatus == "SUCCESS"
+	}
+	return Payment, r.TotalFee, r.TradeState == "SUCCESS"
+}
+
+func billStatus(r *common.BillRecord) string {
+	if r.TradeState == "REFUND" && r.RefundStatus != "" {
+		return r.TradeState + "/" + r.RefundStatus
+	}
+	return r.TradeState
+}
+
+// entryKey 支付按商户订单号, 退款按商户订单号和退款单号匹配
+func entryKey(kind Kind, tradeNum, outRefundNo string) string {
+	if kind == Refund {
+		return string(kind) + "|" + tradeNum + "|" + outRefundNo
+	}
+	return string(kind) + "|" + tradeNum
+}
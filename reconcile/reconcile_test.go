@@ -0,0 +1,157 @@
+package reconcile
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/sulrex/gopay/client"
+)
+
+type memLedger struct {
+	entries []*Entry
+	at      map[*Entry]time.Time
+}
+
+func (l *memLedger) Lookup(ctx context.Context, kind Kind, tradeNum, outRefundNo string) (*Entry, error) {
+	for _, e := range l.entries {
+		if entryKey(e.Kind, e.TradeNum, e.OutRefundNo) == entryKey(kind, tradeNum, outRefundNo) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *memLedger) Settled(ctx context.Context, start, end time.Time, fn func(*Entry) error) error {
+	for _, e := range l.entries {
+		if t := l.at[e]; e.Settled && !t.Before(start) && t.Before(end) {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const wechatBill = "交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\r\n" +
+	"`2020-01-02 10:00:00,`wx1,`100,`0,`,`4200001,`T1,`o1,`JSAPI,`SUCCESS,`CMC,`CNY,`1.00,`0.00,`0,`0,`0.00,`0.00,`,`,`a,`,`0.01000,`0.60%,`1.00,`0.00,`\r\n" +
+	"`2020-01-02 11:00:00,`wx1,`100,`0,`,`4200002,`T2,`o1,`JSAPI,`SUCCESS,`CMC,`CNY,`2.00,`0.00,`0,`0,`0.00,`0.00,`,`,`b,`,`0.01000,`0.60%,`2.00,`0.00,`\r\n" +
+	"`2020-01-02 12:00:00,`wx1,`100,`0,`,`4200003,`T3,`o1,`JSAPI,`SUCCESS,`CMC,`CNY,`3.00,`0.00,`0,`0,`0.00,`0.00,`,`,`c,`,`0.02000,`0.60%,`3.00,`0.00,`\r\n" +
+	"`2020-01-02 13:00:00,`wx1,`100,`0,`,`4200004,`T4,`o1,`JSAPI,`SUCCESS,`CMC,`CNY,`4.00,`0.00,`0,`0,`0.00,`0.00,`,`,`d,`,`0.02000,`0.60%,`4.00,`0.00,`\r\n" +
+	"`2020-01-02 13:30:00,`wx1,`100,`0,`,`4200007,`T7,`o1,`MICROPAY,`REVOKED,`CMC,`CNY,`7.00,`0.00,`0,`0,`0.00,`0.00,`,`,`g,`,`0.00000,`0.60%,`7.00,`0.00,`\r\n" +
+	"`2020-01-02 14:00:00,`wx1,`100,`0,`,`4200001,`T1,`o1,`JSAPI,`REFUND,`CMC,`CNY,`1.00,`0.00,`5000001,`R1,`0.50,`0.00,`ORIGINAL,`SUCCESS,`a,`,`-0.00300,`0.60%,`1.00,`0.50,`\r\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+	"`6,`10.00,`0.50,`0.00,`0.05700,`17.00,`0.50\r\n"
+
+func TestReconcile(t *testing.T) {
+	start, end := BillPeriod(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+	day := start.Add(time.Hour)
+	ledger := &memLedger{at: make(map[*Entry]time.Time)}
+	for _, e := range []*Entry{
+		{Kind: Payment, TradeNum: "T1", Amount: 100, Settled: true, Status: "PAID"},
+		{Kind: Payment, TradeNum: "T2", Amount: 200, Settled: false, Status: "WAITING"}, // 状态不一致
+		{Kind: Payment, TradeNum: "T3", Amount: 299, Settled: true, Status: "PAID"},     // 金额不一致
+		{Kind: Refund, TradeNum: "T1", OutRefundNo: "R1", Amount: 50, Settled: true, Status: "REFUNDED"},
+		{Kind: Payment, TradeNum: "T5", Amount: 500, Settled: true, Status: "PAID"},                       // 账单中没有
+		{Kind: Payment, TradeNum: "T6", Amount: 600, Settled: true, Status: "PAID"},                       // 不在账单日内
+		{Kind: Refund, TradeNum: "T5", OutRefundNo: "R5", Amount: 100, Settled: false, Status: "PENDING"}, // 未成功不要求出现在账单中
+		{Kind: Payment, TradeNum: "T7", Amount: 700, Settled: false, Status: "CANCELLED"},                 // 已撤销, 不计入金额
+	} {
+		ledger.entries = append(ledger.entries, e)
+		ledger.at[e] = day
+	}
+	ledger.at[ledger.entries[5]] = end
+
+	report, err := Reconcile(context.Background(), client.ParseWechatBill(strings.NewReader(wechatBill)), ledger, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]DiffType{}
+	for _, d := range report.Differences {
+		got[d.TradeNum+d.OutRefundNo] = d.Type
+	}
+	want := map[string]DiffType{"T2": StatusMismatch, "T3": AmountMismatch, "T4": MissingInLedger, "T5": MissingInBill}
+	if len(got) != len(want) || len(report.Differences) != len(want) {
+		t.Fatalf("differences %+v, want %v", report.Differences, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("differences %+v, want %v", report.Differences, want)
+		}
+	}
+	f := report.Fees
+	if report.Matched != 3 || f.Payments != 4 || f.Refunds != 1 || f.PaymentAmount != 1000 || f.RefundAmount != 50 || f.ServiceFee != 6 || f.Summary == nil || f.Summary.TradeCount != 6 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[0] != "type,kind,tradeNum,outRefundNo,transactionId,billAmount,ledgerAmount,billStatus,ledgerStatus" ||
+		!strings.Contains(buf.String(), "AMOUNT_MISMATCH,PAYMENT,T3,,4200003,300,299,SUCCESS,PAID") {
+		t.Fatalf("unexpected csv %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Differences) != 4 || decoded.Fees.ServiceFee != 6 {
+		t.Fatalf("unexpected json %s, %v", buf.String(), err)
+	}
+}
+
+func TestReconcileAliBill(t *testing.T) {
+	detail := "#支付宝业务明细查询\r\n" +
+		"支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户,订单金额（元）,商家实收（元）,支付宝红包（元）,集分宝（元）,支付宝优惠（元）,商家优惠（元）,券核销金额（元）,券名称,商家红包消费金额（元）,卡消费金额（元）,退款批次号/请求号,服务费（元）,分润（元）,备注\r\n" +
+		"2016081721001004050200116681\t,A1\t,交易\t,a\t,2016-08-17 10:00:00\t,2016-08-17 10:00:05\t,\t,\t,\t,\t,abc@163.com\t,12.30\t,12.30\t,0.00\t,0.00\t,0.00\t,0.00\t,0.00\t,\t,0.00\t,0.00\t,\t,-0.07\t,0.00\t,\r\n" +
+		"2016081721001004050200116682\t,A2\t,交易\t,b\t,2016-08-17 10:30:00\t,2016-08-17 10:30:05\t,\t,\t,\t,\t,abc@163.com\t,5.00\t,5.00\t,0.00\t,0.00\t,0.00\t,0.00\t,0.00\t,\t,0.00\t,0.00\t,\t,-0.03\t,0.00\t,\r\n" +
+		"2016081721001004050200116681\t,A1\t,退款\t,a\t,2016-08-17 11:00:00\t,2016-08-17 11:00:01\t,\t,\t,\t,\t,abc@163.com\t,-2.30\t,-2.30\t,0.00\t,0.00\t,0.00\t,0.00\t,0.00\t,\t,0.00\t,0.00\t,A1-1\t,0.01\t,0.00\t,\r\n"
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	gbkName, _ := simplifiedchinese.GBK.NewEncoder().String("20886_20160817_业务明细.csv")
+	gbkBody, _ := simplifiedchinese.GBK.NewEncoder().String(detail)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: gbkName, NonUTF8: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(gbkBody))
+	zw.Close()
+	it, err := client.ParseAliBill(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	start, end := BillPeriod(time.Date(2016, 8, 17, 0, 0, 0, 0, time.UTC))
+	ledger := &memLedger{at: make(map[*Entry]time.Time)}
+	for _, e := range []*Entry{
+		{Kind: Payment, TradeNum: "A1", Amount: 1230, Settled: true, Status: "PAID"},
+		{Kind: Refund, TradeNum: "A1", OutRefundNo: "A1-1", Amount: 230, Settled: true, Status: "REFUNDED"},
+		{Kind: Payment, TradeNum: "A2", Amount: 500, Settled: false, Status: "WAITING"}, // 状态不一致
+	} {
+		ledger.entries = append(ledger.entries, e)
+		ledger.at[e] = start.Add(10 * time.Hour)
+	}
+
+	report, err := Reconcile(context.Background(), it, ledger, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Differences) != 1 || report.Differences[0].TradeNum != "A2" || report.Differences[0].Type != StatusMismatch {
+		t.Fatalf("unexpected differences %+v", report.Differences)
+	}
+	f := report.Fees
+	if report.Matched != 2 || f.Payments != 2 || f.Refunds != 1 || f.PaymentAmount != 1730 || f.RefundAmount != 230 || f.ServiceFee != 9 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
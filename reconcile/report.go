@@ -0,0 +1,102 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sulrex/gopay/common"
+)
+
+// DiffType 差异类型
+type DiffType string
+
+// 差异类型
+const (
+	MissingInLedger DiffType = "MISSING_IN_LEDGER" // 账单中有, 商户账本中没有
+	MissingInBill   DiffType = "MISSING_IN_BILL"   // 商户账本中成功, 账单中没有
+	AmountMismatch  DiffType = "AMOUNT_MISMATCH"   // 金额不一致
+	StatusMismatch  DiffType = "STATUS_MISMATCH"   // 状态不一致, 如账单已支付而商户未支付
+)
+
+// Difference 一条对账差异, 金额为最小货币单位(分)
+type Difference struct {
+	Type          DiffType `json:"type"`
+	Kind          Kind     `json:"kind"`
+	TradeNum      string   `json:"tradeNum"`
+	OutRefundNo   string   `json:"outRefundNo,omitempty"`
+	TransactionID string   `json:"transactionId,omitempty"`
+	BillAmount    int64    `json:"billAmount"`
+	LedgerAmount  int64    `json:"ledgerAmount"`
+	BillStatus    string   `json:"billStatus,omitempty"`
+	LedgerStatus  string   `json:"ledgerStatus,omitempty"`
+}
+
+// Fees 账单金额和手续费合计, 金额为最小货币单位(分)
+type Fees struct {
+	Payments      int64               `json:"payments"`          // 支付笔数
+	Refunds       int64               `json:"refunds"`           // 退款笔数
+	PaymentAmount int64               `json:"paymentAmount"`     // 支付总额
+	RefundAmount  int64               `json:"refundAmount"`      // 退款总额
+	SettlementFee int64               `json:"settlementFee"`     // 应结订单总额
+	ServiceFee    int64               `json:"serviceFee"`        // 手续费合计, 退款退回的手续费为负
+	Summary       *common.BillSummary `json:"summary,omitempty"` // 渠道账单汇总, 供核对
+}
+
+// Report 对账结果
+type Report struct {
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"`
+	Matched     int64        `json:"matched"` // 一致的记录数
+	Differences []Difference `json:"differences"`
+	Fees        Fees         `json:"fees"`
+}
+
+// OK 没有差异
+func (r *Report) OK() bool {
+	return len(r.Differences) == 0
+}
+
+func (r *Report) add(d Difference) {
+	r.Differences = append(r.Differences, d)
+}
+
+// addFees 只累计成功的支付和退款, 与渠道汇总口径一致
+func (r *Report) addFees(kind Kind, settled bool, b *common.BillRecord) {
+	if !settled {
+		return
+	}
+	if kind == Refund {
+		r.Fees.Refunds++
+		r.Fees.RefundAmount += b.RefundFee
+	} else {
+		r.Fees.Payments++
+		r.Fees.PaymentAmount += b.TotalFee
+		r.Fees.SettlementFee += b.SettlementFee
+	}
+	r.Fees.ServiceFee += b.ServiceFee
+}
+
+// WriteJSON 输出完整报告
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// csvHeader 差异csv的表头, 与Difference的json字段一致
+var csvHeader = []string{"type", "kind", "tradeNum", "outRefundNo", "transactionId", "billAmount", "ledgerAmount", "billStatus", "ledgerStatus"}
+
+// WriteCSV 输出差异明细, 每条差异一行
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, d := range r.Differences {
+		cw.Write([]string{string(d.Type), string(d.Kind), d.TradeNum, d.OutRefundNo, d.TransactionID,
+			strconv.FormatInt(d.BillAmount, 10), strconv.FormatInt(d.LedgerAmount, 10), d.BillStatus, d.LedgerStatus})
+	}
+	cw.Flush()
+	return cw.Error()
+}
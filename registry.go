@@ -0,0 +1,100 @@
+package gopay
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+)
+
+var (
+	// ErrUnsupportedPayMethod 支付方式未注册
+	ErrUnsupportedPayMethod = errors.New("gopay: unsupported pay method")
+	// ErrClientNotConfigured 支付方式对应的客户端未初始化
+	ErrClientNotConfigured = errors.New("gopay: pay client not configured")
+)
+
+// ClientFunc 获取支付方式对应的客户端, 未初始化时返回nil
+type ClientFunc func(payMethod int64) common.PayClient
+
+type payMethod struct {
+	client ClientFunc
+	rules  []Rule
+}
+
+// 已注册的支付方式
+var payMethods = struct {
+	sync.RWMutex
+	m map[int64]payMethod
+}{m: make(map[int64]payMethod)}
+
+func init() {
+	RegisterPayMethod(constant.ALI_WEB, func(int64) common.PayClient { return client.DefaultAliWebClient() },
+		// 手机网站支付的CallbackURL是同步跳转地址, 异步通知地址在客户端配置; subject不截断, 最长256字
+		withRules(aliRules, CheckURL("callbackURL", callbackURL, false, true), CheckLength("describe", describe, 1, 256))...)
+	RegisterPayMethod(constant.ALI_APP, func(int64) common.PayClient { return client.DefaultAliAppClient() },
+		withRules(aliRules, CheckURL("callbackURL", callbackURL, true, true))...)
+	RegisterPayMethod(constant.WECHAT_WEB, func(int64) common.PayClient { return client.DefaultWechatWebClient() },
+		withRules(wechatRules, requireOpenID)...)
+	RegisterPayMethod(constant.WECHAT_APP, func(int64) common.PayClient { return client.DefaultWechatAppClient() },
+		wechatRules...)
+	RegisterPayMethod(constant.WECHAT_MINI_PROGRAM, func(int64) common.PayClient { return client.DefaultWechatMiniProgramClient() },
+		withRules(wechatRules, requireOpenID)...)
+
+	wechatV3 := func(payMethod int64) common.PayClient { return client.DefaultWechatV3Client(payMethod) }
+	RegisterPayMethod(constant.WECHAT_V3_JSAPI, wechatV3, withRules(wechatV3Rules, requireOpenID)...)
+	RegisterPayMethod(constant.WECHAT_V3_MINI_PROGRAM, wechatV3, withRules(wechatV3Rules, requireOpenID)...)
+	RegisterPayMethod(constant.WECHAT_V3_APP, wechatV3, wechatV3Rules...)
+	RegisterPayMethod(constant.WECHAT_V3_H5, wechatV3, wechatV3Rules...)
+	RegisterPayMethod(constant.WECHAT_V3_NATIVE, wechatV3, wechatV3Rules...)
+}
+
+// RegisterPayMethod 注册支付方式, 已注册的会被覆盖. rules为下单前的参数校验规则
+func RegisterPayMethod(method int64, c ClientFunc, rules ...Rule) {
+	if method <= 0 {
+		panic("gopay: RegisterPayMethod with non-positive pay method")
+	}
+	if c == nil {
+		panic("gopay: RegisterPayMethod with nil ClientFunc")
+	}
+	payMethods.Lock()
+	defer payMethods.Unlock()
+	payMethods.m[method] = payMethod{client: c, rules: append([]Rule{}, rules...)}
+}
+
+func lookupPayMethod(method int64) (payMethod, bool) {
+	payMethods.RLock()
+	defer payMethods.RUnlock()
+	pm, ok := payMethods.m[method]
+	return pm, ok
+}
+
+func unsupportedPayMethod(method int64) error {
+	return fmt.Errorf("%w: %d", ErrUnsupportedPayMethod, method)
+}
+
+// getPayClient 得到需要支付的客户端
+func getPayClient(method int64) (common.PayClient, error) {
+	pm, ok := lookupPayMethod(method)
+	if !ok {
+		return nil, unsupportedPayMethod(method)
+	}
+	ct := pm.client(method)
+	if isNilClient(ct) {
+		return nil, fmt.Errorf("%w: pay method %d", ErrClientNotConfigured, method)
+	}
+	return ct, nil
+}
+
+// isNilClient 判断客户端是否为nil, 包括装在接口里的nil指针
+func isNilClient(ct common.PayClient) bool {
+	if ct == nil {
+		return true
+	}
+	v := reflect.ValueOf(ct)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
@@ -0,0 +1,48 @@
+package gopay
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+)
+
+type fakeClient struct{}
+
+func (fakeClient) Pay(charge *common.Charge) (map[string]string, error) {
+	return map[string]string{"tradeNum": charge.TradeNum}, nil
+}
+
+func TestPayMethodRegistry(t *testing.T) {
+	charge := &common.Charge{PayMethod: 1000, TradeNum: "11111111126", MoneyFee: 1}
+	if _, err := Pay(charge); !errors.Is(err, ErrUnsupportedPayMethod) {
+		t.Fatalf("Pay returned %v, want ErrUnsupportedPayMethod", err)
+	}
+
+	RegisterPayMethod(1000, func(int64) common.PayClient { return fakeClient{} }, CheckAmount(0.01, 100))
+	defer func() {
+		payMethods.Lock()
+		delete(payMethods.m, 1000)
+		payMethods.Unlock()
+	}()
+	re, err := Pay(charge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re["tradeNum"] != charge.TradeNum {
+		t.Fatalf("unexpected pay result %v", re)
+	}
+	charge.MoneyFee = 101
+	if _, err := Pay(charge); err == nil {
+		t.Fatal("Pay ignored the registered rules")
+	}
+
+	client.InitWxWebClient(nil)
+	charge = &common.Charge{PayMethod: constant.WECHAT_WEB, TradeNum: "11111111127", MoneyFee: 1, Describe: "test pay",
+		OpenID: "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", CallbackURL: "https://example.com/callback"}
+	if _, err := Pay(charge); !errors.Is(err, ErrClientNotConfigured) {
+		t.Fatalf("Pay returned %v, want ErrClientNotConfigured", err)
+	}
+}
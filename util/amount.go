@@ -0,0 +1,52 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ParseAmount 精确解析十进制金额字符串为最小货币单位, decimals为小数位数.
+// 如ParseAmount("12.30", 2)返回1230, 不经过浮点数
+func ParseAmount(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("util: empty amount")
+	}
+	neg := strings.HasPrefix(s, "-")
+	if neg || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, errors.New("util: invalid amount " + s)
+	}
+	// 多出的小数位只能是0
+	if len(fracPart) > decimals {
+		if strings.Trim(fracPart[decimals:], "0") != "" {
+			return 0, errors.New("util: amount " + s + " has more than " + strconv.Itoa(decimals) + " decimals")
+		}
+		fracPart = fracPart[:decimals]
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+	digits := intPart + fracPart
+	if digits == "" {
+		digits = "0"
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errors.New("util: invalid amount " + s)
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("util: invalid amount " + s)
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		s        string
+		decimals int
+		want     int64
+	}{
+		{"0.01", 2, 1},
+		{"12.3", 2, 1230},
+		{"1.10", 2, 110},
+		{"100", 0, 100},
+		{"100.00", 0, 100},
+		{"-5.5", 2, -550},
+		{".5", 2, 50},
+	}
+	for _, c := range cases {
+		got, err := ParseAmount(c.s, c.decimals)
+		if err != nil || got != c.want {
+			t.Errorf("ParseAmount(%q, %d) = %d, %v, want %d", c.s, c.decimals, got, err, c.want)
+		}
+	}
+	for _, s := range []string{"", "1.001", "1e3", "abc", "."} {
+		if _, err := ParseAmount(s, 2); err == nil {
+			t.Errorf("ParseAmount(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// 时间和随机串来源, 测试时可替换以得到稳定的请求内容
+var (
+	sourceMu    sync.RWMutex
+	clock                   = time.Now
+	nonceSource NonceSource = RandNonce{}
+)
+
+// SetNonceSource 设置随机串来源, 传nil恢复默认
+func SetNonceSource(s NonceSource) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	if s == nil {
+		s = RandNonce{}
+	}
+	nonceSource = s
+}
+
+// Now 当前时间, 请求中的时间戳都取自这里
+func Now() time.Time {
+	sourceMu.RLock()
+	defer sourceMu.RUnlock()
+	return clock()
+}
+
+// SetClock 设置时间来源, 传nil恢复默认
+func SetClock(f func() time.Time) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	if f == nil {
+		f = time.Now
+	}
+	clock = f
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies 可信代理网段, 只有来自这些地址的X-Forwarded-For和X-Real-IP才被采信
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies 解析可信代理, 支持单个IP和CIDR
+func ParseTrustedProxies(proxies ...string) (TrustedProxies, error) {
+	var re TrustedProxies
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, errors.New("util: invalid proxy ip " + p)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			re = append(re, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, errors.New("util: " + err.Error())
+		}
+		re = append(re, ipNet)
+	}
+	return re, nil
+}
+
+// Contains 判断ip是否属于可信代理
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, ipNet := range t {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 获取请求的客户端IP.
+// 直连地址是可信代理时, 从右向左取X-Forwarded-For中第一个不可信的地址, 其次取X-Real-IP
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	remote := parseIP(r.RemoteAddr)
+	if remote == nil {
+		return ""
+	}
+	if !t.Contains(remote) {
+		return remote.String()
+	}
+
+	var forwarded []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	var leftmost net.IP
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := parseIP(forwarded[i])
+		if ip == nil {
+			break
+		}
+		if !t.Contains(ip) {
+			return ip.String()
+		}
+		leftmost = ip
+	}
+	if leftmost != nil {
+		return leftmost.String()
+	}
+	if ip := parseIP(r.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return remote.String()
+}
+
+// ClientIP 获取请求的客户端IP, 不采信任何代理头
+func ClientIP(r *http.Request) string {
+	return TrustedProxies(nil).ClientIP(r)
+}
+
+// parseIP 解析IP, 兼容带端口和方括号的写法
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if i := strings.Index(s, "%"); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
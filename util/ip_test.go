@@ -0,0 +1,40 @@
+package util
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8", "::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		remote, forwarded, realIP string
+		want                      string
+	}{
+		{"203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"}, // 直连不可信, 忽略代理头
+		{"10.0.0.2:5000", "198.51.100.1, 203.0.113.9, 10.0.0.3", "", "203.0.113.9"},
+		{"10.0.0.2:5000", "10.0.0.4, 10.0.0.3", "", "10.0.0.4"},
+		{"10.0.0.2:5000", "", "2001:db8::1", "2001:db8::1"},
+		{"[::1]:5000", "[2001:db8::2]:443", "", "2001:db8::2"},
+	}
+	for _, c := range cases {
+		r := &http.Request{RemoteAddr: c.remote, Header: http.Header{}}
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := proxies.ClientIP(r); got != c.want {
+			t.Errorf("ClientIP(%s, %q, %q) = %s, want %s", c.remote, c.forwarded, c.realIP, got, c.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Fatal("ParseTrustedProxies accepted invalid ip")
+	}
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// 随机串默认长度和字符集, 与微信nonce_str要求一致(不长于32位)
+const (
+	DefaultNonceLength   = 32
+	DefaultNonceAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// NonceSource 随机串来源
+type NonceSource interface {
+	Nonce() string
+}
+
+// FixedNonce 固定随机串, 测试用
+type FixedNonce string
+
+// Nonce ..
+func (n FixedNonce) Nonce() string {
+	return string(n)
+}
+
+// RandNonce 基于crypto/rand的随机串, 零值使用默认长度和字符集
+type RandNonce struct {
+	Length   int    // 长度
+	Alphabet string // 字符集, 不超过256个字节
+}
+
+// Nonce 生成随机串, 系统随机源不可用时panic
+func (n RandNonce) Nonce() string {
+	length, alphabet := n.Length, n.Alphabet
+	if length <= 0 {
+		length = DefaultNonceLength
+	}
+	if alphabet == "" || len(alphabet) > 256 {
+		alphabet = DefaultNonceAlphabet
+	}
+
+	// 拒绝采样, 避免取模带来的偏差
+	limit := 256 - 256%len(alphabet)
+	var re = make([]byte, 0, length)
+	var buf = make([]byte, length+length/4)
+	for len(re) < length {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			panic("util: crypto/rand: " + err.Error())
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			re = append(re, alphabet[int(b)%len(alphabet)])
+			if len(re) == length {
+				break
+			}
+		}
+	}
+	return string(re)
+}
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRandNonce(t *testing.T) {
+	s := RandNonce{}.Nonce()
+	if len(s) != DefaultNonceLength {
+		t.Fatalf("len = %d, want %d", len(s), DefaultNonceLength)
+	}
+	if s == (RandNonce{}).Nonce() {
+		t.Fatal("two nonces are equal")
+	}
+
+	s = RandNonce{Length: 100, Alphabet: "ab"}.Nonce()
+	if len(s) != 100 || strings.Trim(s, "ab") != "" {
+		t.Fatalf("unexpected nonce %q", s)
+	}
+}
+
+func TestSetNonceSource(t *testing.T) {
+	SetNonceSource(FixedNonce("nonce"))
+	if RandomStr() != "nonce" {
+		t.Fatal("RandomStr did not use the injected source")
+	}
+	SetNonceSource(nil)
+	if len(RandomStr()) != DefaultNonceLength {
+		t.Fatal("SetNonceSource(nil) did not restore the default")
+	}
+}
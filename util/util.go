@@ -2,29 +2,34 @@ package util
 
 import (
 	"encoding/json"
-	"fmt"
 	"net"
-	"time"
 )
 
 //RandomStr 获取一个随机字符串
 func RandomStr() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	sourceMu.RLock()
+	defer sourceMu.RUnlock()
+	return nonceSource.Nonce()
 }
 
-// LocalIP 获取机器的IP
+// LocalIP 获取机器的IP, 优先IPv4, 没有时返回全局IPv6地址.
+// 只应在拿不到付款人IP时兜底
 func LocalIP() string {
 	info, _ := net.InterfaceAddrs()
+	var v6 string
 	for _, addr := range info {
 		ipNet, ok := addr.(*net.IPNet)
-		if !ok {
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
 			continue
 		}
-		if !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+		if ipNet.IP.To4() != nil {
 			return ipNet.IP.String()
 		}
+		if v6 == "" && ipNet.IP.IsGlobalUnicast() {
+			v6 = ipNet.IP.String()
+		}
 	}
-	return ""
+	return v6
 }
 
 // MapStringToStruct ...
@@ -0,0 +1,175 @@
+package gopay
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sulrex/gopay/client"
+	"github.com/sulrex/gopay/common"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string // Charge的json字段名
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError 支付参数校验错误, 包含全部不合法的字段
+type ValidationError struct {
+	PayMethod int64
+	Errors    []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("invalid charge for payMethod %d: %s", e.PayMethod, strings.Join(msgs, "; "))
+}
+
+// Rule 支付参数校验规则, 通过时返回nil
+type Rule func(charge *common.Charge) *FieldError
+
+// 订单号可用字符
+const (
+	wechatTradeNumChars = "_-|*"
+	aliTradeNumChars    = "_"
+)
+
+// 单笔金额上限(元)
+const maxMoneyFee = 100000000
+
+// 各渠道的基础校验规则
+var (
+	requireOpenID = CheckRequired("openid", func(c *common.Charge) string { return c.OpenID })
+	// 商品描述超长时客户端会截断(微信v2和支付宝app为32字, 微信v3为127字), 只要求非空
+	requireDescribe = CheckRequired("describe", describe)
+
+	wechatRules = []Rule{
+		CheckTradeNum(1, 32, wechatTradeNumChars),
+		CheckAmount(0.01, maxMoneyFee),
+		requireDescribe,
+		CheckURL("callbackURL", callbackURL, true, false),
+	}
+	wechatV3Rules = []Rule{
+		CheckTradeNum(6, 32, wechatTradeNumChars),
+		CheckAmount(0.01, maxMoneyFee),
+		requireDescribe,
+		CheckURL("callbackURL", callbackURL, false, false), // 为空时使用客户端配置
+	}
+	aliRules = []Rule{
+		CheckTradeNum(1, 64, aliTradeNumChars),
+		CheckAmount(0.01, maxMoneyFee),
+		requireDescribe,
+	}
+)
+
+// Validate 按支付方式注册的规则校验支付参数, 不合法时返回*ValidationError,
+// 支付方式未注册时返回ErrUnsupportedPayMethod
+func Validate(charge *common.Charge) error {
+	if charge == nil {
+		return &ValidationError{Errors: []FieldError{{Field: "charge", Message: "is nil"}}}
+	}
+	pm, ok := lookupPayMethod(charge.PayMethod)
+	if !ok {
+		return unsupportedPayMethod(charge.PayMethod)
+	}
+	verr := &ValidationError{PayMethod: charge.PayMethod}
+	for _, rule := range pm.rules {
+		if fe := rule(charge); fe != nil {
+			verr.Errors = append(verr.Errors, *fe)
+		}
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// CheckTradeNum 商户订单号长度在[minLen, maxLen]之间, 只含字母数字和chars中的字符
+func CheckTradeNum(minLen, maxLen int, chars string) Rule {
+	return func(c *common.Charge) *FieldError {
+		if len(c.TradeNum) < minLen || len(c.TradeNum) > maxLen {
+			return &FieldError{"tradeNum", fmt.Sprintf("length must be between %d and %d", minLen, maxLen)}
+		}
+		for _, r := range c.TradeNum {
+			if !isAlnum(r) && !strings.ContainsRune(chars, r) {
+				return &FieldError{"tradeNum", fmt.Sprintf("invalid character %q, only letters, digits and %q allowed", r, chars)}
+			}
+		}
+		return nil
+	}
+}
+
+// CheckAmount 金额在[min, max]之间, 且按币种转为最小货币单位后至少为1, 如日元金额不能小于1
+func CheckAmount(min, max float64) Rule {
+	return func(c *common.Charge) *FieldError {
+		if c.MoneyFee < min || c.MoneyFee > max {
+			return &FieldError{"MoneyFee", fmt.Sprintf("must be between %v and %v", min, max)}
+		}
+		if client.MinorAmount(c.MoneyFee, c.Currency) < 1 {
+			return &FieldError{"MoneyFee", "must be at least one minor unit of the currency"}
+		}
+		return nil
+	}
+}
+
+// CheckRequired 字段不能为空
+func CheckRequired(field string, get func(*common.Charge) string) Rule {
+	return func(c *common.Charge) *FieldError {
+		if get(c) == "" {
+			return &FieldError{field, "is required"}
+		}
+		return nil
+	}
+}
+
+// CheckLength 字段字符数在[min, max]之间
+func CheckLength(field string, get func(*common.Charge) string, min, max int) Rule {
+	return func(c *common.Charge) *FieldError {
+		if n := utf8.RuneCountInString(get(c)); n < min || n > max {
+			return &FieldError{field, fmt.Sprintf("length must be between %d and %d characters", min, max)}
+		}
+		return nil
+	}
+}
+
+// CheckURL 字段为http(s)绝对地址, allowQuery为false时不能带参数
+func CheckURL(field string, get func(*common.Charge) string, required, allowQuery bool) Rule {
+	return func(c *common.Charge) *FieldError {
+		s := get(c)
+		if s == "" {
+			if required {
+				return &FieldError{field, "is required"}
+			}
+			return nil
+		}
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &FieldError{field, "must be an absolute http(s) URL"}
+		}
+		if !allowQuery && u.RawQuery != "" {
+			return &FieldError{field, "must not contain query parameters"}
+		}
+		return nil
+	}
+}
+
+// withRules 在基础规则后追加规则, 不修改base
+func withRules(base []Rule, extra ...Rule) []Rule {
+	return append(append([]Rule{}, base...), extra...)
+}
+
+func describe(c *common.Charge) string    { return c.Describe }
+func callbackURL(c *common.Charge) string { return c.CallbackURL }
+
+func isAlnum(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,82 @@
+package gopay
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sulrex/gopay/common"
+	"github.com/sulrex/gopay/constant"
+)
+
+func TestValidate(t *testing.T) {
+	charge := &common.Charge{
+		PayMethod:   constant.WECHAT_WEB,
+		TradeNum:    "11111111125",
+		MoneyFee:    0.01,
+		Describe:    "test pay",
+		OpenID:      "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o",
+		CallbackURL: "https://example.com/callback",
+	}
+	if err := Validate(charge); err != nil {
+		t.Fatal(err)
+	}
+
+	charge.TradeNum = "1111 1111"
+	charge.MoneyFee = 0
+	charge.OpenID = ""
+	charge.CallbackURL = "https://example.com/callback?id=1"
+	err := Validate(charge)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate returned %T, want *ValidationError", err)
+	}
+	var fields []string
+	for _, fe := range verr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	want := []string{"tradeNum", "MoneyFee", "callbackURL", "openid"}
+	if len(fields) != len(want) {
+		t.Fatalf("invalid fields %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Fatalf("invalid fields %v, want %v", fields, want)
+		}
+	}
+
+	// 支付宝订单号不允许'-', 手机网站支付不要求回调地址
+	charge = &common.Charge{PayMethod: constant.ALI_WEB, TradeNum: "2017-01", MoneyFee: 1, Describe: "test pay"}
+	if err := Validate(charge); err == nil {
+		t.Fatal("Validate accepted '-' in alipay tradeNum")
+	}
+	charge.TradeNum = "2017_01"
+	if err := Validate(charge); err != nil {
+		t.Fatal(err)
+	}
+
+	// 日元没有辅币, 0.3日元提交时为0
+	charge.Currency = constant.JPY
+	charge.MoneyFee = 0.3
+	if err := Validate(charge); err == nil {
+		t.Fatal("Validate accepted 0.3 JPY")
+	}
+	charge.MoneyFee = 100
+	if err := Validate(charge); err != nil {
+		t.Fatal(err)
+	}
+
+	// 手机网站支付subject不截断, 超长时拒绝; 会截断描述的支付方式只要求非空
+	charge.Describe = strings.Repeat("测", 257)
+	if err := Validate(charge); err == nil {
+		t.Fatal("Validate accepted 257 characters describe for ALI_WEB")
+	}
+	charge = &common.Charge{PayMethod: constant.WECHAT_APP, TradeNum: "2017_01", MoneyFee: 1, Describe: strings.Repeat("测", 257),
+		CallbackURL: "https://example.com/callback"}
+	if err := Validate(charge); err != nil {
+		t.Fatal(err)
+	}
+	charge.Describe = ""
+	if err := Validate(charge); err == nil {
+		t.Fatal("Validate accepted empty describe")
+	}
+}